@@ -0,0 +1,175 @@
+package dudect
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"runtime"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const default_baseline_path = "dudect.baseline"
+
+// default_check_budget is the budget of a check when none is given, since a
+// check must end to be compared with its baseline.
+const default_check_budget = 1e6
+
+var (
+	// ErrBaseline is returned when a baseline file cannot be used.
+	ErrBaseline = errors.New("dudect: invalid baseline")
+	// ErrRegression is returned by a check whose result is worse than its baseline.
+	ErrRegression = errors.New("dudect: leakage regression")
+)
+
+// environment identifies the machine a baseline was recorded on, results from
+// different machines being hardly comparable.
+type environment struct {
+	GOOS, GOARCH, GoVersion string
+	CPU                     string
+	NumCPU                  int
+}
+
+func current_environment() environment {
+	return environment{
+		GOOS:      runtime.GOOS,
+		GOARCH:    runtime.GOARCH,
+		GoVersion: runtime.Version(),
+		CPU:       cpu_model(),
+		NumCPU:    runtime.NumCPU(),
+	}
+}
+
+// baseline is the result of a run of a target.
+type baseline struct {
+	MaxT         float64
+	MaxTau       float64
+	Measurements float64
+	Verdict      string
+	Environment  environment
+	Time         time.Time
+}
+
+// leak_level ranks the verdicts, from constant time to definitely not.
+func leak_level(max_t float64) int {
+	switch {
+	case max_t > t_threshold_bananas:
+		return 2
+	case max_t > t_threshold_moderate:
+		return 1
+	}
+	return 0
+}
+
+func current_baseline() baseline {
+	mt := max_test()
+	max_t := math.Abs(t_compute(&tests[mt]))
+	return baseline{
+		MaxT:         max_t,
+		MaxTau:       max_t / (tests[mt].n[0] + tests[mt].n[1]),
+		Measurements: measurements(),
+		Verdict:      verdict(max_t),
+		Environment:  current_environment(),
+		Time:         time.Now().UTC(),
+	}
+}
+
+// read_baselines reads the baselines of a file, keyed by the target and its
+// arguments, a missing file holding none.
+func read_baselines(path string) (map[string]baseline, error) {
+	baselines := make(map[string]baseline)
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return baselines, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &baselines); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrBaseline, err)
+	}
+	return baselines, nil
+}
+
+func write_baselines(path string, baselines map[string]baseline) error {
+	b, err := json.MarshalIndent(baselines, "", "\t")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// compare_baseline prints the differences between a baseline and the current
+// result, and returns ErrRegression if the verdict worsened or if a leak grew
+// beyond the given relative tolerance on tau.
+func compare_baseline(base, cur baseline, tolerance float64) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "\tbaseline\tcurrent\n")
+	fmt.Fprintf(w, "measurements\t%.0f\t%.0f\n", base.Measurements, cur.Measurements)
+	fmt.Fprintf(w, "max t\t%.2f\t%.2f\n", base.MaxT, cur.MaxT)
+	fmt.Fprintf(w, "max tau\t%.2e\t%.2e\n", base.MaxTau, cur.MaxTau)
+	fmt.Fprintf(w, "verdict\t%s\t%s\n", base.Verdict, cur.Verdict)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if base.Environment != cur.Environment {
+		fmt.Printf("WARNING: the baseline was recorded on another environment (%+v), the results may not be comparable.\n", base.Environment)
+	}
+
+	if leak_level(cur.MaxT) > leak_level(base.MaxT) {
+		return fmt.Errorf("%w: the verdict went from %q to %q", ErrRegression, base.Verdict, cur.Verdict)
+	}
+	// the tau of a constant time target is only noise, it is compared once
+	// a leak is detected.
+	if leak_level(cur.MaxT) > 0 && cur.MaxTau > base.MaxTau*(1+tolerance) {
+		return fmt.Errorf("%w: max tau grew from %.2e to %.2e, beyond the %.0f%% tolerance",
+			ErrRegression, base.MaxTau, cur.MaxTau, 100*tolerance)
+	}
+	return nil
+}
+
+func check_main(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	run_flags(fs)
+	baseline_path := fs.String("baseline", default_baseline_path, "the file holding the baselines")
+	update := fs.Bool("update", false, "record the result as the new baseline instead of checking it")
+	tolerance := fs.Float64("tolerance", 0.5, "the relative growth of max tau tolerated for a leaking target")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	baselines, err := read_baselines(*baseline_path)
+	if err != nil {
+		return err
+	}
+	t, err := LookupTarget(fs.Args())
+	if err != nil {
+		return err
+	}
+	if budget == 0 {
+		budget = default_check_budget
+	}
+	if err := Run(t); err != nil {
+		return err
+	}
+	if measurements() < budget {
+		return fmt.Errorf("check interrupted after %.0f measurements, expected %.0f", measurements(), budget)
+	}
+
+	key := strings.Join(fs.Args(), " ")
+	cur := current_baseline()
+	base, ok := baselines[key]
+	if ok && !*update {
+		fmt.Printf("\nchecking %q against its baseline of %s:\n", key, base.Time.Format(time.RFC3339))
+		return compare_baseline(base, cur, *tolerance)
+	}
+	baselines[key] = cur
+	if err := write_baselines(*baseline_path, baselines); err != nil {
+		return err
+	}
+	fmt.Printf("\nbaseline of %q written to %s\n", key, *baseline_path)
+	return nil
+}
@@ -0,0 +1,103 @@
+package dudect
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCompareBaseline(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		base, cur  baseline
+		tolerance  float64
+		regression bool
+	}{
+		{"constant time", baseline{MaxT: 2, MaxTau: 1e-3}, baseline{MaxT: 3, MaxTau: 2e-3}, 0.5, false},
+		{"new leak", baseline{MaxT: 2, MaxTau: 1e-3}, baseline{MaxT: 10, MaxTau: 1e-3}, 0.5, true},
+		{"worse leak", baseline{MaxT: 10, MaxTau: 1e-3}, baseline{MaxT: 600, MaxTau: 1e-3}, 0.5, true},
+		{"fixed leak", baseline{MaxT: 10, MaxTau: 1e-3}, baseline{MaxT: 2, MaxTau: 1e-4}, 0.5, false},
+		{"tau within the tolerance", baseline{MaxT: 10, MaxTau: 1e-3}, baseline{MaxT: 12, MaxTau: 1.4e-3}, 0.5, false},
+		{"tau beyond the tolerance", baseline{MaxT: 10, MaxTau: 1e-3}, baseline{MaxT: 12, MaxTau: 1.6e-3}, 0.5, true},
+		{"tau beyond a lower tolerance", baseline{MaxT: 10, MaxTau: 1e-3}, baseline{MaxT: 12, MaxTau: 1.4e-3}, 0.2, true},
+		{"tau of a constant time target", baseline{MaxT: 1, MaxTau: 1e-5}, baseline{MaxT: 4, MaxTau: 1e-2}, 0.5, false},
+	} {
+		tc.base.Verdict, tc.cur.Verdict = verdict(tc.base.MaxT), verdict(tc.cur.MaxT)
+		err := compare_baseline(tc.base, tc.cur, tc.tolerance)
+		if tc.regression && !errors.Is(err, ErrRegression) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, ErrRegression)
+		}
+		if !tc.regression && err != nil {
+			t.Errorf("%s: %v", tc.name, err)
+		}
+	}
+}
+
+func TestReadBaselines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "baseline")
+	baselines, err := read_baselines(path)
+	if err != nil || len(baselines) != 0 {
+		t.Fatalf("reading a missing file: got %v, %v, want no baselines", baselines, err)
+	}
+
+	baselines["synthetic -delta 1"] = baseline{MaxT: 10, MaxTau: 1e-3, Verdict: verdict(10)}
+	if err := write_baselines(path, baselines); err != nil {
+		t.Fatal(err)
+	}
+	read, err := read_baselines(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if read["synthetic -delta 1"] != baselines["synthetic -delta 1"] {
+		t.Errorf("got %+v, want %+v", read, baselines)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := read_baselines(path); !errors.Is(err, ErrBaseline) {
+		t.Errorf("reading a malformed file: got %v, want %v", err, ErrBaseline)
+	}
+}
+
+func TestCheckMain(t *testing.T) {
+	defer reset_statistics()
+	defer func(b float64) { budget = b }(budget)
+	path := filepath.Join(t.TempDir(), "baseline")
+	check := func(args ...string) error {
+		reset_statistics()
+		return check_main(append([]string{"-budget", "6000", "-baseline", path}, append(args, "synthetic")...))
+	}
+
+	// without a baseline file, the result becomes the baseline.
+	if err := check(); err != nil {
+		t.Fatal(err)
+	}
+	baselines, err := read_baselines(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, ok := baselines["synthetic"]; !ok || b.Measurements < 6000 {
+		t.Fatalf("got the baselines %+v, want one of at least 6000 measurements for synthetic", baselines)
+	}
+
+	// a baseline so bad that no result can be worse.
+	baselines["synthetic"] = baseline{MaxT: 1e6, MaxTau: 1, Verdict: verdict(1e6)}
+	if err := write_baselines(path, baselines); err != nil {
+		t.Fatal(err)
+	}
+	if err := check(); err != nil {
+		t.Error(err)
+	}
+	if baselines, err = read_baselines(path); err != nil || baselines["synthetic"].MaxT != 1e6 {
+		t.Errorf("a check without -update changed the baseline to %+v (%v)", baselines["synthetic"], err)
+	}
+
+	if err := check("-update"); err != nil {
+		t.Fatal(err)
+	}
+	if baselines, err = read_baselines(path); err != nil || baselines["synthetic"].MaxT == 1e6 {
+		t.Errorf("a check with -update kept the baseline %+v (%v)", baselines["synthetic"], err)
+	}
+}
@@ -0,0 +1,110 @@
+package dudect
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// The calibrate command sweeps the size of a synthetic leak, given to the
+// target with its -delta flag, by default to the calibration target.
+const default_calibration_target = "calibration"
+
+// reset_statistics forgets every measurement.
+func reset_statistics() {
+	percentiles = [number_percentiles]int64{}
+	tests = [number_tests]t_ctx{}
+	blocks, block_size, pending, pending_batches, batch_count = nil, 1, t_ctx{}, 0, 0
+	mk_s = 0
+	phases = nil
+	phase_index = make(map[[2]string]int)
+	checked = false
+}
+
+// calibrate_delta measures a new instance of the target given by args, with
+// the given delta, until a leak is detected or n measurements are taken, and
+// returns the max t along with the difference between the class means of its
+// test, in nanoseconds.
+func calibrate_delta(args []string, delta int, n float64) (max_t, difference float64, err error) {
+	reset_statistics()
+	t, err := LookupTarget(append(args[:len(args):len(args)], "-delta", strconv.Itoa(delta)))
+	if err != nil {
+		return 0, 0, err
+	}
+	if t.Close != nil {
+		defer t.Close()
+	}
+	target = t
+	mt := 0
+	for measurements() < n && max_t <= t_threshold_moderate {
+		if err := measure_batch(); err != nil {
+			return 0, 0, err
+		}
+		mt = max_test()
+		max_t = math.Abs(t_compute(&tests[mt]))
+	}
+	return max_t, tests[mt].mean[1] - tests[mt].mean[0], nil
+}
+
+func calibrate_main(args []string) error {
+	fs := flag.NewFlagSet("calibrate", flag.ContinueOnError)
+	n := fs.Float64("n", 1e5, "maximum number of measurements for each delta")
+	max_delta := fs.Int("max", 1<<16, "largest delta tried, in rounds")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: dudect calibrate [flags] [target [target flags]]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	target_args := fs.Args()
+	if len(target_args) == 0 {
+		target_args = []string{default_calibration_target}
+	}
+
+	try := func(delta int) (bool, float64, error) {
+		max_t, difference, err := calibrate_delta(target_args, delta, *n)
+		if err != nil {
+			return false, 0, err
+		}
+		detected := max_t > t_threshold_moderate
+		fmt.Printf("delta %6d rounds: %9.1f ns, max t %+8.2f after %8.0f measurements, detected: %v\n",
+			delta, difference, max_t, measurements(), detected)
+		return detected, difference, nil
+	}
+
+	// the delta is doubled until a leak is detected, the smallest detectable
+	// one is then bisected between the last two deltas.
+	low, high := 0, 0
+	var difference float64
+	for delta := 1; delta <= *max_delta; delta *= 2 {
+		detected, d, err := try(delta)
+		if err != nil {
+			return err
+		}
+		if detected {
+			high, difference = delta, d
+			break
+		}
+		low = delta
+	}
+	if high == 0 {
+		fmt.Printf("no leak of up to %d rounds was detected within %.0f measurements\n", *max_delta, *n)
+		return nil
+	}
+	for high-low > 1 {
+		mid := (low + high) / 2
+		detected, d, err := try(mid)
+		if err != nil {
+			return err
+		}
+		if detected {
+			high, difference = mid, d
+		} else {
+			low = mid
+		}
+	}
+	fmt.Printf("smallest leak detected within %.0f measurements: %d rounds, about %.1f ns\n", *n, high, difference)
+	return nil
+}
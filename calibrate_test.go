@@ -0,0 +1,34 @@
+package dudect
+
+import "testing"
+
+func TestCalibrateDelta(t *testing.T) {
+	defer reset_statistics()
+	max_t, difference, err := calibrate_delta([]string{"synthetic"}, 2000, 1e5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if max_t <= t_threshold_moderate || difference <= 0 {
+		t.Errorf("a delta of 2000 rounds went undetected: max t = %.2f, difference = %.1f ns", max_t, difference)
+	}
+}
+
+func TestLookupInstances(t *testing.T) {
+	a, err := LookupTarget([]string{"synthetic", "-delta", "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := LookupTarget([]string{"synthetic", "-delta", "3000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// an input of class 1, the result being spin(200 + delta).
+	for _, tc := range []struct {
+		t      *Target
+		rounds int
+	}{{a, 200}, {b, 3200}} {
+		if got := tc.t.DoOneComputation([]byte{1, 0})[1]; got != spin(tc.rounds) {
+			t.Errorf("%q: got %#x, want spin(%d) = %#x", tc.t.args, got, tc.rounds, spin(tc.rounds))
+		}
+	}
+}
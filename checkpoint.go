@@ -0,0 +1,108 @@
+package dudect
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+const default_checkpoint_path = "dudect.checkpoint"
+
+// ErrCheckpoint is returned when a checkpoint file cannot be used.
+var ErrCheckpoint = errors.New("dudect: invalid checkpoint")
+
+// checkpoint_path is where the checkpoint is written at the end of a run,
+// and resume_path the checkpoint a run starts from, if not empty.
+var checkpoint_path string
+var resume_path string
+
+type checkpoint_ctx struct {
+	Mean, M2, N [2]float64
+}
+
+// checkpoint holds the statistics needed to resume a run, along with the
+// target they were measured on.
+type checkpoint struct {
+	Target      string
+	Args        []string
+	Percentiles []int64
+	Tests       []checkpoint_ctx
+	// Blocks holds the blocks of batches of the drift detection, of
+	// BlockSize batches each, along with the batches of the block being
+	// filled summarized in Pending.
+	Blocks         []checkpoint_ctx
+	BlockSize      int
+	Pending        checkpoint_ctx
+	PendingBatches int
+	BatchCount     int
+	MannKendall    float64
+}
+
+func to_checkpoint_ctx(ctx *t_ctx) checkpoint_ctx {
+	return checkpoint_ctx{Mean: ctx.mean, M2: ctx.m2, N: ctx.n}
+}
+
+func from_checkpoint_ctx(c checkpoint_ctx) t_ctx {
+	return t_ctx{mean: c.Mean, m2: c.M2, n: c.N}
+}
+
+func write_checkpoint(path string) error {
+	c := checkpoint{
+		Target:         target.Name,
+		Args:           target.args,
+		Percentiles:    percentiles[:],
+		BlockSize:      block_size,
+		Pending:        to_checkpoint_ctx(&pending),
+		PendingBatches: pending_batches,
+		BatchCount:     batch_count,
+		MannKendall:    mk_s,
+	}
+	for i := range tests {
+		c.Tests = append(c.Tests, to_checkpoint_ctx(&tests[i]))
+	}
+	for i := range blocks {
+		c.Blocks = append(c.Blocks, to_checkpoint_ctx(&blocks[i]))
+	}
+	b, err := json.Marshal(&c)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+func load_checkpoint(path string) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var c checkpoint
+	if err := json.Unmarshal(b, &c); err != nil {
+		return fmt.Errorf("%w: %v", ErrCheckpoint, err)
+	}
+	if c.Target != target.Name || !slices.Equal(c.Args, target.args) {
+		return fmt.Errorf("%w: written for %q, not for %q", ErrCheckpoint,
+			strings.Join(append([]string{c.Target}, c.Args...), " "), target.command_line())
+	}
+	if len(c.Percentiles) != number_percentiles || len(c.Tests) != number_tests {
+		return fmt.Errorf("%w: expected %d percentiles and %d tests, got %d and %d",
+			ErrCheckpoint, number_percentiles, number_tests, len(c.Percentiles), len(c.Tests))
+	}
+	if c.BlockSize < 1 || len(c.Blocks) >= drift_max_blocks {
+		return fmt.Errorf("%w: %d blocks of %d batches", ErrCheckpoint, len(c.Blocks), c.BlockSize)
+	}
+	copy(percentiles[:], c.Percentiles)
+	for i := range tests {
+		tests[i] = from_checkpoint_ctx(c.Tests[i])
+	}
+	blocks = blocks[:0]
+	for _, b := range c.Blocks {
+		blocks = append(blocks, from_checkpoint_ctx(b))
+	}
+	block_size, pending, pending_batches = c.BlockSize, from_checkpoint_ctx(c.Pending), c.PendingBatches
+	batch_count = c.BatchCount
+	mk_s = c.MannKendall
+	return nil
+}
@@ -0,0 +1,83 @@
+package dudect
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpoint(t *testing.T) {
+	reset_statistics()
+	defer reset_statistics()
+	path := filepath.Join(t.TempDir(), "checkpoint")
+
+	target = synthetic_target(200)
+	target.args = []string{"-x", "1"}
+	for i := 0; i < 3; i++ {
+		if err := measure_batch(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := write_checkpoint(path); err != nil {
+		t.Fatal(err)
+	}
+	want_tests, want_count := tests, batch_count
+
+	reset_statistics()
+	if err := load_checkpoint(path); err != nil {
+		t.Fatal(err)
+	}
+	if tests != want_tests || batch_count != want_count {
+		t.Errorf("the statistics were not restored")
+	}
+
+	for _, args := range [][]string{nil, {"-x", "2"}} {
+		target.args = args
+		if err := load_checkpoint(path); !errors.Is(err, ErrCheckpoint) {
+			t.Errorf("loading with arguments %q: got %v, want %v", args, err, ErrCheckpoint)
+		}
+	}
+	target = synthetic_target(200)
+	target.Name = "other"
+	target.args = []string{"-x", "1"}
+	if err := load_checkpoint(path); !errors.Is(err, ErrCheckpoint) {
+		t.Errorf("loading for another target: got %v, want %v", err, ErrCheckpoint)
+	}
+}
+
+func TestCheckResumed(t *testing.T) {
+	reset_statistics()
+	defer reset_statistics()
+	path := filepath.Join(t.TempDir(), "checkpoint")
+
+	target = synthetic_target(200)
+	checks := 0
+	target.CheckComputation = func(data, result []byte, class int) error {
+		checks++
+		return nil
+	}
+	for i := 0; i < 2; i++ {
+		if err := measure_batch(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := number_measurements + check_samples; checks != want {
+		t.Errorf("checked %d inputs of two batches, want %d", checks, want)
+	}
+	if err := write_checkpoint(path); err != nil {
+		t.Fatal(err)
+	}
+
+	// the computation is checked again on the whole first batch of a resumed run.
+	reset_statistics()
+	if err := load_checkpoint(path); err != nil {
+		t.Fatal(err)
+	}
+	checks = 0
+	if err := measure_batch(); err != nil {
+		t.Fatal(err)
+	}
+	if checks != number_measurements {
+		t.Errorf("checked %d inputs of the first batch of a resumed run, want %d", checks, number_measurements)
+	}
+}
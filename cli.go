@@ -0,0 +1,117 @@
+package dudect
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+)
+
+const usage = `usage: dudect [-plugin file.so]... command
+
+  dudect list                      list the registered targets
+  dudect run [flags] target [target flags]
+                                   assess the given target
+  dudect calibrate [flags] [target [target flags]]
+                                   find the smallest leak detectable on this host,
+                                   sweeping the -delta flag of the calibration target
+  dudect check [flags] target [target flags]
+                                   assess the given target against its baseline
+  dudect compare [flags] target [target flags] [-- target [target flags]]...
+                                   assess several implementations, or the variants
+                                   of a target, side by side
+  dudect plan [flags] [target [target flags]]
+                                   estimate the measurements needed to detect a leak
+  dudect plot [flags] trace        plot the measurements of a trace file
+  dudect scaffold [flags]          write a main package wrapping packages registering targets
+
+Each -plugin loads the targets of a Go plugin built with -buildmode=plugin.
+Run "dudect <command> -h" for the flags of a command.
+`
+
+// run_flags binds the options of a run to the given flag set.
+func run_flags(fs *flag.FlagSet) {
+	fs.BoolVar(&disable_gc, "nogc", false, "disable the garbage collector while measuring, collecting between batches instead")
+	fs.BoolVar(&measure_allocs, "allocs", false, "count the allocations of each computation and t-test them")
+	fs.BoolVar(&pretouch_inputs, "pretouch", false, "touch the inputs and timestamps before each timed batch")
+	fs.BoolVar(&isolate, "isolate", false, "lock the measurements to a pinned OS thread with a raised priority (Linux only)")
+	fs.IntVar(&isolation_cpu, "cpu", -1, "the cpu used by -isolate, the last one the process may run on if negative")
+	fs.BoolVar(&report_effect, "effect", false, "report the timing difference with confidence intervals and effect sizes")
+	fs.BoolVar(&probing, "phases", false, "t-test separately the phases of the computation delimited by the probes of the target")
+	fs.StringVar(&trace_path, "trace", "", "write every measurement to the given trace file")
+	fs.StringVar(&plot_prefix, "plot", "", "periodically write SVG and PNG plots to files starting with the given prefix")
+	fs.BoolVar(&plot_ascii, "ascii", false, "periodically render the plots in the terminal")
+	fs.BoolVar(&dashboard, "dashboard", false, "display a live dashboard refreshed in place instead of one report per batch")
+	fs.Float64Var(&budget, "budget", 0, "stop after the given number of measurements, never if 0")
+	fs.StringVar(&checkpoint_path, "checkpoint", "", "write a checkpoint to the given file on Ctrl-C or when the budget is reached")
+	fs.StringVar(&resume_path, "resume", "", "resume from the given checkpoint file")
+	fs.StringVar(&metrics_addr, "http", "", "serve the live statistics and pprof on the given address, e.g. localhost:6060")
+}
+
+func list_main() error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	for _, t := range Targets() {
+		fmt.Fprintf(w, "%s\t%s\n", t.Name, t.Description)
+	}
+	return w.Flush()
+}
+
+func run_main(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	run_flags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	t, err := LookupTarget(fs.Args())
+	if err != nil {
+		return err
+	}
+	return Run(t)
+}
+
+// Main runs the dudect command line on the given arguments, without the
+// program name, using the registered targets.
+func Main(args []string) error {
+	for len(args) > 0 && (args[0] == "-plugin" || args[0] == "--plugin") {
+		if len(args) < 2 {
+			return fmt.Errorf("%w: -plugin requires a file", ErrPlugin)
+		}
+		if err := load_plugin(args[1]); err != nil {
+			return err
+		}
+		args = args[2:]
+	}
+	if len(args) == 0 {
+		fmt.Print(usage)
+		return nil
+	}
+	var err error
+	switch args[0] {
+	case "list":
+		err = list_main()
+	case "run":
+		err = run_main(args[1:])
+	case "calibrate":
+		err = calibrate_main(args[1:])
+	case "check":
+		err = check_main(args[1:])
+	case "compare":
+		err = compare_main(args[1:])
+	case "plan":
+		err = plan_main(args[1:])
+	case "plot":
+		err = plot_main(args[1:])
+	case "scaffold":
+		err = scaffold_main(args[1:])
+	case "help", "-h", "-help", "--help":
+		fmt.Print(usage)
+	default:
+		err = fmt.Errorf("unknown command %q, run dudect help for the usage", args[0])
+	}
+	// the flag package already printed the usage of the command.
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	return err
+}
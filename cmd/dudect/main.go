@@ -0,0 +1,22 @@
+// Command dudect assesses whether the registered targets run in constant time.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/AnomalRoil/go-dudect"
+	_ "github.com/AnomalRoil/go-dudect/targets/calibration"
+	_ "github.com/AnomalRoil/go-dudect/targets/ctarget"
+	_ "github.com/AnomalRoil/go-dudect/targets/leftpad"
+	_ "github.com/AnomalRoil/go-dudect/targets/network"
+	_ "github.com/AnomalRoil/go-dudect/targets/rsa"
+	_ "github.com/AnomalRoil/go-dudect/targets/subprocess"
+)
+
+func main() {
+	if err := dudect.Main(os.Args[1:]); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,190 @@
+package dudect
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	mrand "math/rand"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// implementation is one of the targets of a comparison, with its own
+// statistics. The statistics of the implementation being measured are loaded
+// into the package-level ones, so that the usual machinery applies.
+type implementation struct {
+	target      *Target
+	percentiles [number_percentiles]int64
+	tests       [number_tests]t_ctx
+}
+
+func (impl *implementation) load() {
+	target, percentiles, tests = impl.target, impl.percentiles, impl.tests
+}
+
+func (impl *implementation) save() {
+	impl.percentiles, impl.tests = percentiles, tests
+}
+
+// mean_time returns the mean execution time over both classes, in nanoseconds.
+func (impl *implementation) mean_time() float64 {
+	ctx := &impl.tests[0]
+	return (ctx.mean[0]*ctx.n[0] + ctx.mean[1]*ctx.n[1]) / (ctx.n[0] + ctx.n[1])
+}
+
+// split_targets splits the arguments of compare on "--", each group being a
+// target followed by its own arguments.
+func split_targets(args []string) [][]string {
+	groups := [][]string{nil}
+	for _, arg := range args {
+		if arg == "--" {
+			groups = append(groups, nil)
+			continue
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], arg)
+	}
+	return groups
+}
+
+func compare_main(args []string) error {
+	fs := flag.NewFlagSet("compare", flag.ContinueOnError)
+	fs.Float64Var(&budget, "budget", 0, "stop after the given number of measurements of each target, never if 0")
+	fs.BoolVar(&disable_gc, "nogc", false, "disable the garbage collector while measuring, collecting between batches instead")
+	fs.BoolVar(&pretouch_inputs, "pretouch", false, "touch the inputs and timestamps before each timed batch")
+	seed := fs.Int64("seed", 0, "seed of the inputs and of the order in which the targets are measured, drawn from the clock if 0")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: dudect compare [flags] target [target flags] [-- target [target flags]]...")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var targets []*Target
+	for _, group := range split_targets(fs.Args()) {
+		t, err := LookupTarget(group)
+		if err != nil {
+			return err
+		}
+		if t.Close != nil {
+			defer t.Close()
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 1 && targets[0].Variants != nil {
+		targets = targets[0].Variants()
+	}
+	if len(targets) < 2 {
+		return fmt.Errorf("compare: expected at least two targets separated by --, or a target with variants")
+	}
+	impls := make([]*implementation, len(targets))
+	for i, t := range targets {
+		impls[i] = &implementation{target: t}
+	}
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+	fmt.Printf("dudect compare: %d targets, seed %d\n", len(impls), *seed)
+	return compare(impls, *seed)
+}
+
+// compare measures the implementations on the same inputs, drawn from the
+// first one, batch after batch and in a random order for each batch so that
+// any drift of the machine affects all of them alike. Both the inputs and the
+// order are drawn from seed, if the first implementation can be seeded.
+func compare(impls []*implementation, seed int64) error {
+	if impls[0].target.Seed != nil {
+		impls[0].target.Seed(seed)
+	} else {
+		fmt.Printf("WARNING: %s cannot be seeded, its inputs are not reproducible.\n", impls[0].target.Name)
+	}
+	rn := mrand.New(mrand.NewSource(seed))
+	order := make([]int, len(impls))
+	for i := range order {
+		order[i] = i
+	}
+	for first_batch := true; ; first_batch = false {
+		input_data, classes, err := impls[0].target.PrepareInputs(number_measurements)
+		if err != nil {
+			return err
+		}
+		if err := validate_inputs(input_data, classes); err != nil {
+			return err
+		}
+
+		rn.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+		for _, i := range order {
+			impls[i].load()
+			if err := compare_batch(input_data, classes, first_batch); err != nil {
+				return fmt.Errorf("%s: %w", target.command_line(), err)
+			}
+			impls[i].save()
+		}
+
+		done := budget > 0 && measurements() >= budget
+		if done {
+			fmt.Printf("\nbudget reached, final report:\n")
+		}
+		if err := compare_report(impls); err != nil || done {
+			return err
+		}
+	}
+}
+
+// compare_batch measures the current target on a batch of inputs, which are
+// left untouched for the other targets.
+func compare_batch(input_data [][]byte, classes []int, first_batch bool) error {
+	// the checked computations run on the inputs themselves, unlike the
+	// measured ones which run on copies in the measurement arena.
+	if err := check_batch(copy_inputs(input_data), classes, first_batch); err != nil {
+		return err
+	}
+	gc_percent := 0
+	if disable_gc {
+		gc_percent = stop_gc()
+	}
+	exec_times, err := measure(input_data)
+	if disable_gc {
+		restart_gc(gc_percent)
+	}
+	if err != nil {
+		return err
+	}
+	if first_batch {
+		if err := prepare_percentiles(exec_times); err != nil {
+			return err
+		}
+	}
+	update_statistics(exec_times, classes)
+	return nil
+}
+
+// copy_inputs returns a copy of the inputs.
+func copy_inputs(input_data [][]byte) [][]byte {
+	inputs := make([][]byte, len(input_data))
+	for i := range input_data {
+		inputs[i] = append([]byte(nil), input_data[i]...)
+	}
+	return inputs
+}
+
+// compare_report prints the max t and tau of each implementation, along with
+// its mean execution time relative to the fastest one.
+func compare_report(impls []*implementation) error {
+	fastest := math.Inf(1)
+	for _, impl := range impls {
+		fastest = math.Min(fastest, impl.mean_time())
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(w, "target\tmeas (M)\tmax t\tmax tau\tmean (ns)\toverhead\t\n")
+	for _, impl := range impls {
+		impl.load()
+		mt := max_test()
+		max_t := math.Abs(t_compute(&tests[mt]))
+		max_tau := max_t / (tests[mt].n[0] + tests[mt].n[1])
+		mean := impl.mean_time()
+		fmt.Fprintf(w, "%s\t%.2f\t%+.2f\t%.2e\t%.1f\t%+.1f%%\t %s\n",
+			impl.target.command_line(), measurements()/1e6, max_t, max_tau, mean, 100*(mean/fastest-1), verdict(max_t))
+	}
+	return w.Flush()
+}
@@ -0,0 +1,85 @@
+package dudect
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// compare_run compares two synthetic targets on a batch drawn from seed, and
+// returns the classes of the inputs along with the order in which the targets
+// ran their computations.
+func compare_run(t *testing.T, seed int64) (classes []int, order []int) {
+	reset_statistics()
+	defer reset_statistics()
+	defer func(b float64) { budget = b }(budget)
+	budget = 2 * number_measurements
+
+	var impls []*implementation
+	for i := 0; i < 2; i++ {
+		i, target := i, synthetic_target(0)
+		prepare_inputs, computation := target.PrepareInputs, target.DoOneComputation
+		target.PrepareInputs = func(n int) ([][]byte, []int, error) {
+			input_data, c, err := prepare_inputs(n)
+			classes = append(classes, c...)
+			return input_data, c, err
+		}
+		target.DoOneComputation = func(data []byte) []byte {
+			if len(order) == 0 || order[len(order)-1] != i {
+				order = append(order, i)
+			}
+			return computation(data)
+		}
+		impls = append(impls, &implementation{target: target})
+	}
+	if err := compare(impls, seed); err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func TestCompareSeed(t *testing.T) {
+	classes, order := compare_run(t, 1)
+	if len(classes) != 2*number_measurements || len(order) != 4 {
+		t.Fatalf("got %d inputs measured in the order %v, want %d inputs measured twice per batch",
+			len(classes), order, 2*number_measurements)
+	}
+	again_classes, again_order := compare_run(t, 1)
+	if !reflect.DeepEqual(classes, again_classes) || !reflect.DeepEqual(order, again_order) {
+		t.Errorf("two comparisons with the same seed differ")
+	}
+	other_classes, _ := compare_run(t, 2)
+	if reflect.DeepEqual(classes, other_classes) {
+		t.Errorf("two comparisons with different seeds got the same inputs")
+	}
+}
+
+// TestCompareCopy compares targets which overwrite their inputs, which must
+// not affect the inputs of the other targets.
+func TestCompareCopy(t *testing.T) {
+	reset_statistics()
+	defer reset_statistics()
+	defer func(b float64) { budget = b }(budget)
+	budget = number_measurements
+
+	var impls []*implementation
+	for i := 0; i < 3; i++ {
+		target := synthetic_target(0)
+		target.Name = fmt.Sprint("overwriting-", i)
+		target.DoOneComputation = func(data []byte) []byte {
+			result := []byte{data[0]}
+			data[0] = 0xff
+			return result
+		}
+		target.CheckComputation = func(data, result []byte, class int) error {
+			if result[0] != byte(class) {
+				return fmt.Errorf("got an input of class %d", result[0])
+			}
+			return nil
+		}
+		impls = append(impls, &implementation{target: target})
+	}
+	if err := compare(impls, 1); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,139 @@
+package dudect
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const dashboard_refresh = 250 * time.Millisecond
+const dashboard_trend = 48 // number of batches shown in the trend of max t
+
+// dashboard replaces the per-batch reports by a summary refreshed in place.
+var dashboard bool
+
+// budget is the number of measurements after which the run stops, if not 0.
+var budget float64
+
+var run_start time.Time
+var run_start_measurements float64
+var last_render time.Time
+
+// heat_levels shades a t-value, from below 1 to over t_threshold_bananas.
+var heat_levels = []struct {
+	limit float64
+	shade byte
+}{{1, ' '}, {2, '.'}, {3, ':'}, {4, '-'}, {5, '='}, {10, '+'}, {50, '*'}, {100, '#'}, {t_threshold_bananas, '%'}}
+
+func heat(t float64) byte {
+	for _, l := range heat_levels {
+		if t < l.limit {
+			return l.shade
+		}
+	}
+	return '@'
+}
+
+// sparkline renders values as a single line of block characters.
+func sparkline(values []float64) string {
+	blocks := []rune("▁▂▃▄▅▆▇█")
+	high := 0.0
+	for _, v := range values {
+		high = math.Max(high, v)
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if high > 0 {
+			i = int(v / high * float64(len(blocks)-1))
+		}
+		b.WriteRune(blocks[i])
+	}
+	return b.String()
+}
+
+// render_dashboard redraws the dashboard in place, at most every
+// dashboard_refresh unless forced.
+func render_dashboard(force bool) {
+	if !force && time.Since(last_render) < dashboard_refresh {
+		return
+	}
+	var b strings.Builder
+	if last_render.IsZero() {
+		b.WriteString("\033[2J")
+	}
+	last_render = time.Now()
+
+	// move the cursor home, each line then clears what remains of the previous frame.
+	b.WriteString("\033[H")
+	line := func(format string, a ...interface{}) {
+		fmt.Fprintf(&b, format+"\033[K\n", a...)
+	}
+
+	elapsed := time.Since(run_start)
+	n := measurements()
+	throughput := (n - run_start_measurements) / elapsed.Seconds()
+	line("dudect dashboard, running for %v", elapsed.Round(time.Second))
+	line("")
+	line("measurements: %10.0f   throughput: %8.0f /s", n, throughput)
+	if budget > 0 {
+		eta := "unknown"
+		if throughput > 0 {
+			eta = time.Duration(math.Max(budget-n, 0) / throughput * float64(time.Second)).Round(time.Second).String()
+		}
+		line("budget:       %10.0f   ETA: %s (%.1f%%)", budget, eta, 100*math.Min(n/budget, 1))
+	}
+
+	mt := max_test()
+	max_t := math.Abs(t_compute(&tests[mt]))
+	if n < enough_measurements || math.IsNaN(max_t) {
+		line("max t: not enough measurements yet")
+	} else {
+		history := t_history
+		if len(history) > dashboard_trend {
+			history = history[len(history)-dashboard_trend:]
+		}
+		values := make([]float64, len(history))
+		for i, p := range history {
+			values[i] = p.t
+		}
+		trend := "="
+		if len(values) > 1 {
+			switch delta := values[len(values)-1] - values[0]; {
+			case delta > 1:
+				trend = "↑"
+			case delta < -1:
+				trend = "↓"
+			}
+		}
+		line("max t: %+7.2f (test %d), max tau: %.2e, trend %s %s", max_t, mt, max_t/(tests[mt].n[0]+tests[mt].n[1]), trend, sparkline(values))
+		line("verdict: %s", verdict(max_t))
+	}
+
+	line("")
+	line("|t| per test: first order, cropped from the 7th to the 99.9th percentile, second order")
+	var heatmap []byte
+	for i := 0; i < number_tests; i++ {
+		ctx := &tests[i]
+		if ctx.n[0] < 2 || ctx.n[1] < 2 {
+			heatmap = append(heatmap, '?')
+			continue
+		}
+		heatmap = append(heatmap, heat(math.Abs(t_compute(ctx))))
+	}
+	for i := 0; i < len(heatmap); i += 52 {
+		end := i + 52
+		if end > len(heatmap) {
+			end = len(heatmap)
+		}
+		line("  %3d [%s]", i, heatmap[i:end])
+	}
+	var legend []string
+	for _, l := range heat_levels {
+		legend = append(legend, fmt.Sprintf("'%c' <%g", l.shade, l.limit))
+	}
+	line("  %s, '@' above, '?' no data", strings.Join(legend, " "))
+	b.WriteString("\033[J")
+	fmt.Print(b.String())
+}
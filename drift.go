@@ -0,0 +1,137 @@
+package dudect
+
+import (
+	"fmt"
+	"math"
+)
+
+const drift_min_blocks = 10
+const drift_z_threshold = 3 // two-sided p-value of about 0.003
+
+// drift_max_blocks bounds the number of blocks the trend is computed on, so
+// that long runs keep a constant cost per batch and a small checkpoint.
+const drift_max_blocks = 256
+
+// The trend is computed on blocks of consecutive batches: each block holds
+// the summary statistics, on the execution times that were not cropped, of
+// block_size batches. Once drift_max_blocks blocks are full, adjacent blocks
+// are merged and block_size doubles. The batches of the block being filled
+// are summarized in pending.
+var (
+	blocks          []t_ctx
+	block_size      = 1
+	pending         t_ctx
+	pending_batches int
+	// batch_count is the number of batches recorded so far.
+	batch_count int
+)
+
+// mk_s is the Mann-Kendall S statistic on the per-block class-mean
+// differences, it is updated each time a block is completed.
+var mk_s float64
+
+func batch_difference(b *t_ctx) float64 {
+	return b.mean[1] - b.mean[0]
+}
+
+// t_merge returns the summary statistics of the union of two sets of
+// measurements, as in Chan et al.'s parallel algorithm.
+func t_merge(a, b *t_ctx) t_ctx {
+	var m t_ctx
+	for class := 0; class < 2; class++ {
+		n := a.n[class] + b.n[class]
+		if n == 0 {
+			continue
+		}
+		delta := b.mean[class] - a.mean[class]
+		m.n[class] = n
+		m.mean[class] = a.mean[class] + delta*b.n[class]/n
+		m.m2[class] = a.m2[class] + b.m2[class] + delta*delta*a.n[class]*b.n[class]/n
+	}
+	return m
+}
+
+// mk_score returns the contribution to mk_s of a block, compared with all the
+// previous ones.
+func mk_score(previous []t_ctx, b *t_ctx) (s float64) {
+	d := batch_difference(b)
+	for i := range previous {
+		if p := batch_difference(&previous[i]); d > p {
+			s++
+		} else if d < p {
+			s--
+		}
+	}
+	return s
+}
+
+// compact merges adjacent blocks, halving their number, and recomputes mk_s.
+func compact() {
+	merged := blocks[:0]
+	for i := 0; i+1 < len(blocks); i += 2 {
+		merged = append(merged, t_merge(&blocks[i], &blocks[i+1]))
+	}
+	blocks = merged
+	block_size *= 2
+	mk_s = 0
+	for i := range blocks {
+		mk_s += mk_score(blocks[:i], &blocks[i])
+	}
+}
+
+// record_batch summarizes a batch and updates the trend statistic with it.
+func record_batch(exec_times []int64, classes []int) {
+	var b t_ctx
+	for i := 0; i < number_measurements; i++ {
+		if exec_times[i] < 0 {
+			continue
+		}
+		t_push(&b, float64(exec_times[i]), classes[i])
+	}
+	batch_count++
+	pending = t_merge(&pending, &b)
+	if pending_batches++; pending_batches < block_size {
+		return
+	}
+	mk_s += mk_score(blocks, &pending)
+	blocks = append(blocks, pending)
+	pending, pending_batches = t_ctx{}, 0
+	if len(blocks) == drift_max_blocks {
+		compact()
+	}
+}
+
+// drift_z returns the normalized Mann-Kendall statistic: a value far from zero
+// means that the class-mean difference is steadily drifting across blocks.
+func drift_z() float64 {
+	n := float64(len(blocks))
+	sd := math.Sqrt(n * (n - 1) * (2*n + 5) / 18)
+	switch {
+	case mk_s > 0:
+		return (mk_s - 1) / sd
+	case mk_s < 0:
+		return (mk_s + 1) / sd
+	}
+	return 0
+}
+
+// drift_report warns when the class-mean difference drifts across batches,
+// since the t-values may then come from the environment rather than a leak.
+func drift_report(max_t float64) {
+	if len(blocks) < drift_min_blocks {
+		return
+	}
+	z := drift_z()
+	if math.Abs(z) < drift_z_threshold {
+		return
+	}
+	first := batch_difference(&blocks[0])
+	last := batch_difference(&blocks[len(blocks)-1])
+	fmt.Printf("WARNING: the class-mean difference drifts across %d batches (Mann-Kendall z: %+.2f, from %+.1f ns to %+.1f ns).",
+		batch_count, z, first, last)
+	if max_t > t_threshold_moderate {
+		fmt.Printf(" The evidence may come from this drift rather than from a stable leak.\n")
+	} else {
+		fmt.Printf("\n")
+	}
+}
@@ -0,0 +1,62 @@
+package dudect
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestTMerge(t *testing.T) {
+	rn := rand.New(rand.NewSource(1))
+	var a, b, all t_ctx
+	for i := 0; i < 1000; i++ {
+		x, class := 100+rn.NormFloat64()*10, rn.Intn(2)
+		if i < 300 {
+			t_push(&a, x, class)
+		} else {
+			t_push(&b, x, class)
+		}
+		t_push(&all, x, class)
+	}
+	m := t_merge(&a, &b)
+	for class := 0; class < 2; class++ {
+		if m.n[class] != all.n[class] || !close_to(m.mean[class], all.mean[class], 1e-12) || !close_to(m.m2[class], all.m2[class], 1e-9) {
+			t.Errorf("class %d: merged %+v, want %+v", class, m, all)
+		}
+	}
+}
+
+func TestDriftBlocks(t *testing.T) {
+	reset_statistics()
+	defer reset_statistics()
+
+	// the class-mean difference grows steadily with the batches.
+	exec_times := make([]int64, number_measurements)
+	classes := make([]int, number_measurements)
+	const n = 3 * drift_max_blocks
+	for batch := 0; batch < n; batch++ {
+		for i := range exec_times {
+			classes[i] = i % 2
+			exec_times[i] = 1000 + int64(classes[i]*batch) + int64(i%7)
+		}
+		record_batch(exec_times, classes)
+		if len(blocks) >= drift_max_blocks {
+			t.Fatalf("%d blocks after %d batches, want less than %d", len(blocks), batch+1, drift_max_blocks)
+		}
+	}
+	if batch_count != n {
+		t.Errorf("batch_count = %d, want %d", batch_count, n)
+	}
+	if got := len(blocks)*block_size + pending_batches; got != n {
+		t.Errorf("the blocks hold %d batches, want %d", got, n)
+	}
+	var s float64
+	for i := range blocks {
+		s += mk_score(blocks[:i], &blocks[i])
+	}
+	if s != mk_s {
+		t.Errorf("mk_s = %v, want %v", mk_s, s)
+	}
+	if z := drift_z(); z < drift_z_threshold {
+		t.Errorf("a steady drift went undetected: z = %.2f", z)
+	}
+}
@@ -1,10 +1,17 @@
+// Package dudect assesses whether a function runs in constant time, by t-testing
+// its execution times on two classes of inputs.
+//
 // All credit goes to Oscar Reparaz, Josep Balasch and Ingrid Verbauwhede for dudect's ideas and design
-package main
+package dudect
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"log"
 	"math"
+	"os"
+	"os/signal"
+	"sync"
 	"time"
 )
 
@@ -24,31 +31,184 @@ const t_threshold_moderate = 5  // here we could also take 4.5 e.g.
 const number_percentiles = 100
 const number_tests = 1 + number_percentiles + 1 // we perform 1
 
+// check_samples is the number of inputs of each batch checked after the first
+// one, which is checked whole.
+const check_samples = 30
+
 var percentiles [number_percentiles]int64
 var tests [number_tests]t_ctx
 
-func prepare_percentiles(ticks []int64) {
+// checked tells whether the computation was checked on a whole batch since the
+// run started, which is not the case of a run resumed from a checkpoint.
+var checked bool
+
+// stats_mutex guards the statistics updated after each batch, which may be
+// read concurrently by the metrics server.
+var stats_mutex sync.Mutex
+
+// result_sink receives the result of every computation, much like the sinks used
+// with testing.B, so that the compiler cannot optimize the measured work away.
+var result_sink []byte
+
+// target is the target being measured.
+var target *Target
+
+var (
+	// ErrInvalidClass is returned when an input is labelled with a class other than 0 or 1.
+	ErrInvalidClass = errors.New("dudect: class must be 0 or 1")
+	// ErrInputGeneration is returned when a target fails to produce its inputs.
+	ErrInputGeneration = errors.New("dudect: failed to generate inputs")
+	// ErrInputLength is returned when a target does not return exactly
+	// the requested number of inputs and classes.
+	ErrInputLength = errors.New("dudect: wrong number of inputs or classes")
+	// ErrMissingClass is returned when a batch does not contain both classes.
+	ErrMissingClass = errors.New("dudect: both classes must be present in a batch")
+	// ErrComputationCheck is returned when the check of a target rejects a computation.
+	ErrComputationCheck = errors.New("dudect: computation check failed")
+	// ErrIsolation is returned when the measuring thread cannot be isolated.
+	ErrIsolation = errors.New("dudect: cannot isolate the measuring thread")
+)
+
+// isolate enables the OS-level noise control done by isolate_thread, on the
+// isolation_cpu CPU or on the last one of the affinity mask if it is negative.
+var isolate bool
+var isolation_cpu = -1
+
+// validate_inputs makes sure the inputs returned by the target can be
+// measured, so that a faulty target does not panic in the middle of a run.
+func validate_inputs(input_data [][]byte, classes []int) error {
+	if len(input_data) != number_measurements || len(classes) != number_measurements {
+		return fmt.Errorf("%w: got %d inputs and %d classes, expected %d",
+			ErrInputLength, len(input_data), len(classes), number_measurements)
+	}
+	var seen [2]bool
+	for i, class := range classes {
+		if !(class == 0 || class == 1) {
+			return fmt.Errorf("%w: got %d for input %d", ErrInvalidClass, class, i)
+		}
+		seen[class] = true
+	}
+	if !seen[0] || !seen[1] {
+		return ErrMissingClass
+	}
+	return nil
+}
+
+// lint_computation runs the computation once on each input outside of the
+// timed region and warns when it never returns any result, since the work done
+// by such a computation may be optimized away by the compiler.
+func lint_computation(input_data [][]byte) {
+	for i := range input_data {
+		result_sink = target.DoOneComputation(input_data[i])
+		if len(result_sink) > 0 {
+			return
+		}
+	}
+	fmt.Printf("WARNING: %s returned no result for the whole batch, "+
+		"the measured computation may have been optimized away.\n", target.Name)
+}
+
+// check_inputs runs the computation on every stride-th of the given inputs and
+// passes its result to the check of the target, if any. The result is copied
+// since it may only be valid until the next computation.
+func check_inputs(input_data [][]byte, classes []int, stride int) error {
+	if target.CheckComputation == nil {
+		return nil
+	}
+	for i := 0; i < len(input_data); i += stride {
+		result := append([]byte(nil), target.DoOneComputation(input_data[i])...)
+		if err := target.CheckComputation(input_data[i], result, classes[i]); err != nil {
+			return fmt.Errorf("%w on input %d of class %d: %v", ErrComputationCheck, i, classes[i], err)
+		}
+	}
+	return nil
+}
+
+// check_batch checks the computation on a batch of inputs before it is
+// measured: on all of them along with the lint if whole is set, and otherwise
+// on check_samples of them.
+func check_batch(input_data [][]byte, classes []int, whole bool) error {
+	if !whole {
+		return check_inputs(input_data, classes, len(input_data)/check_samples)
+	}
+	if err := check_inputs(input_data, classes, 1); err != nil {
+		return err
+	}
+	lint_computation(input_data)
+	return nil
+}
+
+func prepare_percentiles(ticks []int64) error {
+	p, err := percentiles_of(ticks)
+	if err != nil {
+		return err
+	}
+	percentiles = p
+	return nil
+}
+
+// percentiles_of returns the cropping thresholds of the given execution times.
+func percentiles_of(ticks []int64) (p [number_percentiles]int64, err error) {
+	// percentile sorts its input, which must not reorder the execution times
+	// with respect to their classes.
+	sorted := append([]int64(nil), ticks...)
 	for i := 0; i < number_percentiles; i++ {
-		percentiles[i] = percentile(
-			ticks, 1-(math.Pow(0.5, float64(10*(i+1))/float64(number_percentiles))))
+		p[i], err = percentile(
+			sorted, 1-(math.Pow(0.5, float64(10*(i+1))/float64(number_percentiles))))
+		if err != nil {
+			return p, err
+		}
 	}
+	return p, nil
 }
 
-func measure(input_data [][]byte) (exec_times []int64) {
-	ticks := make([]int64, number_measurements+1)
+// measure times each computation on its input, failing if the target met an
+// error. The inputs are first copied into measurement_arena, which also holds
+// the timestamps and the returned execution times: these are only valid until
+// the next call to measure.
+func measure(input_data [][]byte) ([]int64, error) {
+	exec_times := time_computations(input_data)
+	if target.Err != nil {
+		if err := target.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return exec_times, nil
+}
+
+func time_computations(input_data [][]byte) (exec_times []int64) {
+	inputs := measurement_arena.load(input_data)
+	if pretouch_inputs {
+		measurement_arena.pretouch()
+	}
+	exec_times = measurement_arena.exec_times
+	if target.Measure != nil {
+		for i := 0; i < number_measurements; i++ {
+			result_sink, exec_times[i] = target.Measure(inputs[i])
+		}
+		return
+	}
+
+	if probing {
+		measure_phases(inputs, exec_times)
+		return
+	}
+
+	ticks := measurement_arena.ticks
 	for i := 0; i < number_measurements; i++ {
 		ticks[i] = time.Now().UnixNano()
-		do_one_computation(input_data[i])
+		result_sink = target.DoOneComputation(inputs[i])
 	}
 
 	ticks[number_measurements] = time.Now().UnixNano()
-	exec_times = make([]int64, number_measurements)
 	for i := 0; i < number_measurements; i++ {
 		exec_times[i] = ticks[i+1] - ticks[i]
 	}
 	return
 }
 
+// update_statistics pushes the execution times of a batch to the t-tests, the
+// classes must have been checked by validate_inputs.
 func update_statistics(exec_times []int64, classes []int) {
 
 	for i := 0; i < number_measurements; i++ {
@@ -76,10 +236,9 @@ func update_statistics(exec_times []int64, classes []int) {
 	}
 }
 
+// t_push adds a measurement of the given class to ctx. The classes are
+// validated where they enter the harness, so class must be 0 or 1.
 func t_push(ctx *t_ctx, x float64, class int) {
-	if !(class == 0 || class == 1) {
-		log.Fatalln("Error, wrong class in t_push")
-	}
 	ctx.n[class]++
 	// Welford method for computing online variance
 	// in a numerically stable way.
@@ -118,13 +277,19 @@ func t_compute(ctx *t_ctx) float64 {
 
 // max_test returns the index of the test with the greateast t-value
 func max_test() int {
+	return max_test_of(tests[:])
+}
+
+// max_test_of returns the index of the test of ctxs with the greatest t-value,
+// among those holding enough measurements.
+func max_test_of(ctxs []t_ctx) int {
 	ret := 0
 	var max float64
 	max = 0.0
-	for i := 0; i < number_tests; i++ {
-		if tests[i].n[0] > enough_measurements {
+	for i := range ctxs {
+		if ctxs[i].n[0] > enough_measurements {
 			var x float64
-			x = math.Abs(t_compute(&tests[i]))
+			x = math.Abs(t_compute(&ctxs[i]))
 			if max < x {
 				max = x
 				ret = i
@@ -179,35 +344,219 @@ func report() {
 		max_tau,
 		float64(5*5)/float64(max_tau*max_tau))
 
+	fmt.Printf(" %s\n", verdict(max_t))
+	drift_report(max_t)
+}
+
+// verdict interprets the max t-value.
+func verdict(max_t float64) string {
 	if max_t > t_threshold_bananas {
-		fmt.Printf(" Definitely not constant time.\n")
-		return
+		return "Definitely not constant time."
 	}
 	if max_t > t_threshold_moderate {
-		fmt.Printf(" Probably not constant time.\n")
-	}
-	if max_t < t_threshold_moderate {
-		fmt.Printf(" For the moment, maybe constant time.\n")
+		return "Probably not constant time."
 	}
+	return "For the moment, maybe constant time."
 }
 
-func doit() {
-	input_data, classes := prepare_inputs()
-	exec_times := measure(input_data)
+// measurements returns the number of measurements taken so far.
+func measurements() float64 {
+	return tests[0].n[0] + tests[0].n[1]
+}
 
+// record_measurements updates the statistics with the execution times of a
+// batch, stats_mutex must be held.
+func record_measurements(exec_times []int64, classes []int, first_batch bool) error {
 	// on the very first run, let's compute the rough esitmate of the percentiles:
-	if percentiles[number_percentiles-1] == 0 {
-		prepare_percentiles(exec_times)
+	if first_batch {
+		if err := prepare_percentiles(exec_times); err != nil {
+			return err
+		}
 	}
 	update_statistics(exec_times, classes)
+	record_batch(exec_times, classes)
+	if plot_prefix != "" || plot_ascii || dashboard {
+		if err := record_plot_data(exec_times, classes); err != nil {
+			return err
+		}
+	}
+	if report_effect {
+		record_samples(exec_times, classes)
+	}
+	if probing {
+		return record_phases(classes)
+	}
+	return nil
+}
+
+// measure_batch measures a new batch of inputs and updates the statistics.
+func measure_batch() error {
+	input_data, classes, err := target.PrepareInputs(number_measurements)
+	if err != nil {
+		return err
+	}
+	if err := validate_inputs(input_data, classes); err != nil {
+		return err
+	}
+	if err := check_batch(input_data, classes, !checked); err != nil {
+		return err
+	}
+	checked = true
+	first_batch := percentiles[number_percentiles-1] == 0
+	gc_percent := 0
+	if disable_gc {
+		gc_percent = stop_gc()
+	}
+	exec_times, err := measure(input_data)
+	if disable_gc {
+		restart_gc(gc_percent)
+	}
+	if err != nil {
+		return err
+	}
+	if trace_writer != nil {
+		if err := write_trace(exec_times, classes); err != nil {
+			return err
+		}
+	}
+
+	stats_mutex.Lock()
+	err = record_measurements(exec_times, classes, first_batch)
+	stats_mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	if measure_allocs {
+		measure_allocations(input_data, classes)
+	}
+	return nil
+}
+
+// print_reports prints the report of the statistics, followed by the other
+// reports enabled for the run.
+func print_reports() {
 	report()
+	if probing {
+		phase_report()
+	}
+	if report_effect {
+		effect_report()
+	}
+	if measure_allocs {
+		alloc_report()
+	}
+}
+
+func doit() error {
+	if err := measure_batch(); err != nil {
+		return err
+	}
+	if dashboard {
+		render_dashboard(false)
+		return live_plots()
+	}
+	print_reports()
+	if plot_prefix != "" || plot_ascii {
+		return live_plots()
+	}
+	return nil
 }
 
-func main() {
-	fmt.Println("dudect start")
+// Run performs measurements of the given target until an error occurs, which
+// is then returned to the caller instead of terminating the process, or until
+// the run is stopped by its budget or by Ctrl-C.
+func Run(t *Target) error {
+	target = t
+	if t.Close != nil {
+		defer t.Close()
+	}
+	fmt.Println("dudect start:", t.Name)
+	if isolate {
+		cpu, err := isolation_cpu_of(isolation_cpu)
+		if err != nil {
+			return err
+		}
+		isolation_cpu = cpu
+		environment_report(isolation_cpu)
+		restore, err := isolate_thread(isolation_cpu)
+		if err != nil {
+			return err
+		}
+		defer restore()
+	}
+	if trace_path != "" {
+		f, err := create_trace(trace_path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+	}
+	if resume_path != "" {
+		if err := load_checkpoint(resume_path); err != nil {
+			return err
+		}
+		fmt.Printf("resuming from %s with %.0f measurements\n", resume_path, measurements())
+	}
+	if metrics_addr != "" {
+		srv, addr, err := serve_metrics(metrics_addr)
+		if err != nil {
+			return err
+		}
+		defer srv.Shutdown(context.Background())
+		fmt.Printf("serving metrics on http://%s/metrics\n", addr)
+	}
+	if dashboard && checkpoint_path == "" {
+		checkpoint_path = default_checkpoint_path
+	}
+
+	// on Ctrl-C, we stop after the current batch to print a final report
+	// and write a checkpoint. The notification is stopped on the first
+	// signal, so that a second Ctrl-C kills the process as usual.
+	interrupted := make(chan struct{})
+	if checkpoint_path != "" {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt)
+		done := make(chan struct{})
+		defer close(done)
+		go func() {
+			defer signal.Stop(signals)
+			select {
+			case <-signals:
+				close(interrupted)
+			case <-done:
+			}
+		}()
+	}
+	run_start, run_start_measurements = time.Now(), measurements()
 
 	for {
-		doit()
+		select {
+		case <-interrupted:
+			return finish("interrupted")
+		default:
+		}
+		if err := doit(); err != nil {
+			return err
+		}
+		if budget > 0 && measurements() >= budget {
+			return finish("budget reached")
+		}
 	}
+}
 
+// finish prints a final report, writing a checkpoint if requested.
+func finish(reason string) error {
+	if dashboard {
+		render_dashboard(true)
+	}
+	fmt.Printf("\n%s, final report:\n", reason)
+	print_reports()
+	if checkpoint_path == "" {
+		return nil
+	}
+	if err := write_checkpoint(checkpoint_path); err != nil {
+		return err
+	}
+	fmt.Printf("checkpoint written to %s\n", checkpoint_path)
+	return nil
 }
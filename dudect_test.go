@@ -0,0 +1,259 @@
+package dudect
+
+import (
+	"errors"
+	"flag"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// two_pass returns the mean and the unbiased variance of x, computed in two
+// passes as a reference for the online computations.
+func two_pass(x []float64) (mean, variance float64) {
+	for _, v := range x {
+		mean += v
+	}
+	mean /= float64(len(x))
+	for _, v := range x {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, variance / float64(len(x)-1)
+}
+
+// welch_t returns Welch's t statistic of a and b, computed in two passes.
+func welch_t(a, b []float64) float64 {
+	mean_a, var_a := two_pass(a)
+	mean_b, var_b := two_pass(b)
+	return (mean_a - mean_b) / math.Sqrt(var_a/float64(len(a))+var_b/float64(len(b)))
+}
+
+func close_to(got, want, tolerance float64) bool {
+	return math.Abs(got-want) <= tolerance*math.Max(1, math.Abs(want))
+}
+
+func push_all(ctx *t_ctx, x []float64, class int) {
+	for _, v := range x {
+		t_push(ctx, v, class)
+	}
+}
+
+func TestTPushWelford(t *testing.T) {
+	rn := rand.New(rand.NewSource(1))
+	// a large offset with a small spread is where a naive sum of squares
+	// loses all of its precision.
+	for _, offset := range []float64{0, 1e3, 1e9} {
+		var x [2][]float64
+		for i := 0; i < 10000; i++ {
+			class := rn.Intn(2)
+			x[class] = append(x[class], offset+rn.NormFloat64()*(1+float64(class)))
+		}
+		var ctx t_ctx
+		for class := range x {
+			push_all(&ctx, x[class], class)
+		}
+		for class := range x {
+			mean, variance := two_pass(x[class])
+			if ctx.n[class] != float64(len(x[class])) {
+				t.Errorf("offset %g, class %d: n = %v, want %d", offset, class, ctx.n[class], len(x[class]))
+			}
+			if !close_to(ctx.mean[class], mean, 1e-12) {
+				t.Errorf("offset %g, class %d: mean = %v, want %v", offset, class, ctx.mean[class], mean)
+			}
+			if got := ctx.m2[class] / (ctx.n[class] - 1); !close_to(got, variance, 1e-6) {
+				t.Errorf("offset %g, class %d: variance = %v, want %v", offset, class, got, variance)
+			}
+		}
+	}
+}
+
+func TestValidateInputs(t *testing.T) {
+	input_data := make([][]byte, number_measurements)
+	classes := make([]int, number_measurements)
+	for i := range classes {
+		classes[i] = i % 2
+	}
+	if err := validate_inputs(input_data, classes); err != nil {
+		t.Fatalf("valid inputs rejected: %v", err)
+	}
+	for _, tc := range []struct {
+		input_data [][]byte
+		classes    []int
+		want       error
+	}{
+		{input_data[1:], classes, ErrInputLength},
+		{input_data, classes[1:], ErrInputLength},
+		{input_data, append(append([]int(nil), classes[1:]...), 2), ErrInvalidClass},
+		{input_data, append(append([]int(nil), classes[1:]...), -1), ErrInvalidClass},
+		{input_data, make([]int, number_measurements), ErrMissingClass},
+	} {
+		if err := validate_inputs(tc.input_data, tc.classes); !errors.Is(err, tc.want) {
+			t.Errorf("validate_inputs returned %v, want %v", err, tc.want)
+		}
+	}
+}
+
+func TestTComputeWelch(t *testing.T) {
+	// the examples of the Wikipedia article on Welch's t-test.
+	for _, tc := range []struct {
+		a, b []float64
+		want float64
+	}{
+		{
+			a:    []float64{27.5, 21.0, 19.0, 23.6, 17.0, 17.9, 16.9, 20.1, 21.9, 22.6, 23.1, 19.6, 19.0, 21.7, 21.4},
+			b:    []float64{27.1, 22.0, 20.8, 23.4, 23.4, 23.5, 25.8, 22.0, 24.8, 20.2, 21.9, 22.1, 22.9, 20.5, 24.4},
+			want: -2.46,
+		},
+		{
+			a: []float64{17.2, 20.9, 22.6, 18.1, 21.7, 21.4, 23.5, 24.2, 14.7, 21.8},
+			b: []float64{21.5, 22.8, 21.0, 23.0, 21.6, 23.6, 22.5, 20.7, 23.4, 21.8,
+				20.7, 21.7, 21.5, 22.5, 23.6, 21.5, 22.5, 23.5, 21.5, 21.8},
+			want: -1.57,
+		},
+	} {
+		var ctx t_ctx
+		push_all(&ctx, tc.a, 0)
+		push_all(&ctx, tc.b, 1)
+		got := t_compute(&ctx)
+		if math.Abs(got-tc.want) > 0.005 {
+			t.Errorf("t_compute = %.4f, want %.2f", got, tc.want)
+		}
+		if want := welch_t(tc.a, tc.b); !close_to(got, want, 1e-12) {
+			t.Errorf("t_compute = %v, want %v from the two-pass computation", got, want)
+		}
+	}
+}
+
+func TestUpdateStatistics(t *testing.T) {
+	reset_statistics()
+	defer reset_statistics()
+
+	rn := rand.New(rand.NewSource(1))
+	var all, second [2][]float64
+	var sums [2]float64
+	var cropped [number_percentiles][2][]float64
+	exec_times := make([]int64, number_measurements)
+	classes := make([]int, number_measurements)
+	for batch := 0; batch < 10; batch++ {
+		for i := range exec_times {
+			classes[i] = rn.Intn(2)
+			exec_times[i] = 1000 + int64(rn.ExpFloat64()*100) + int64(10*classes[i])
+		}
+		if batch == 0 {
+			if err := prepare_percentiles(exec_times); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// the second-order test starts once the first one holds enough
+		// measurements of class 0, it is centered on the running mean.
+		for i, x := range exec_times {
+			class := classes[i]
+			all[class] = append(all[class], float64(x))
+			sums[class] += float64(x)
+			for crop := range percentiles {
+				if x < percentiles[crop] {
+					cropped[crop][class] = append(cropped[crop][class], float64(x))
+				}
+			}
+			if len(all[0]) > 10000 {
+				mean := sums[class] / float64(len(all[class]))
+				second[class] = append(second[class], (float64(x)-mean)*(float64(x)-mean))
+			}
+		}
+		update_statistics(exec_times, classes)
+	}
+
+	if got, want := t_compute(&tests[0]), welch_t(all[0], all[1]); !close_to(got, want, 1e-9) {
+		t.Errorf("first-order t = %v, want %v", got, want)
+	}
+	for crop := range percentiles {
+		if len(cropped[crop][0]) < 2 || len(cropped[crop][1]) < 2 {
+			continue
+		}
+		if got, want := t_compute(&tests[crop+1]), welch_t(cropped[crop][0], cropped[crop][1]); !close_to(got, want, 1e-9) {
+			t.Errorf("t cropped at %d = %v, want %v", percentiles[crop], got, want)
+		}
+	}
+	if len(second[0]) < 2 || len(second[1]) < 2 {
+		t.Fatalf("the second-order test should have started, got %d and %d measurements", len(second[0]), len(second[1]))
+	}
+	if n := tests[1+number_percentiles].n; n[0] != float64(len(second[0])) || n[1] != float64(len(second[1])) {
+		t.Fatalf("second-order test holds %v measurements, want [%d %d]", n, len(second[0]), len(second[1]))
+	}
+	if got, want := t_compute(&tests[1+number_percentiles]), welch_t(second[0], second[1]); !close_to(got, want, 1e-6) {
+		t.Errorf("second-order t = %v, want %v", got, want)
+	}
+}
+
+// spin does n rounds of a linear congruential generator, its result depending
+// on every round so that the loop cannot be optimized away.
+func spin(n int) byte {
+	x := uint64(1)
+	for i := 0; i < n; i++ {
+		x = x*6364136223846793005 + 1442695040888963407
+	}
+	return byte(x >> 56)
+}
+
+// synthetic_target returns a target spinning for 200 rounds on inputs of
+// class 0 and for 200+delay rounds on inputs of class 1, the delay being
+// also set by its -delta flag.
+func synthetic_target(delay int) *Target {
+	rn := rand.New(rand.NewSource(1))
+	return &Target{
+		Name: "synthetic",
+		Configure: func(args []string) error {
+			fs := flag.NewFlagSet("synthetic", flag.ContinueOnError)
+			fs.IntVar(&delay, "delta", delay, "number of extra rounds of class 1")
+			return fs.Parse(args)
+		},
+		PrepareInputs: func(n int) ([][]byte, []int, error) {
+			input_data := make([][]byte, n)
+			classes := make([]int, n)
+			for i := range input_data {
+				classes[i] = rn.Intn(2)
+				input_data[i] = []byte{byte(classes[i]), 0}
+			}
+			return input_data, classes, nil
+		},
+		DoOneComputation: func(data []byte) []byte {
+			data[1] = spin(200 + delay*int(data[0]))
+			return data
+		},
+		Seed: rn.Seed,
+	}
+}
+
+func init() {
+	Register(func() *Target { return synthetic_target(0) })
+}
+
+// run_synthetic measures the given number of batches of a synthetic target
+// and returns the resulting max t.
+func run_synthetic(t *testing.T, delay, number_batches int) float64 {
+	reset_statistics()
+	defer reset_statistics()
+	target = synthetic_target(delay)
+	for i := 0; i < number_batches; i++ {
+		if err := measure_batch(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return math.Abs(t_compute(&tests[max_test()]))
+}
+
+func TestSyntheticLeak(t *testing.T) {
+	for _, delay := range []int{50, 200, 1000} {
+		if max_t := run_synthetic(t, delay, 10); max_t <= t_threshold_moderate {
+			t.Errorf("a delay of %d rounds went undetected: max t = %.2f", delay, max_t)
+		}
+	}
+}
+
+func TestSyntheticConstantTime(t *testing.T) {
+	// the second-order test only starts once 10000 measurements of class 0
+	// are taken, it needs a few more batches to settle.
+	if max_t := run_synthetic(t, 0, 40); max_t > t_threshold_moderate {
+		t.Errorf("a constant time target was flagged: max t = %.2f", max_t)
+	}
+}
@@ -0,0 +1,106 @@
+package dudect
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+const reservoir_size = 1000     // samples kept per class for the bootstrap
+const bootstrap_resamples = 200 // resamples used for each bootstrap interval
+const bootstrap_every = 16      // batches between two bootstrap computations
+const z_95 = 1.959964           // two-sided 95% quantile of the normal distribution
+
+// report_effect enables the report of the timing difference and effect sizes.
+var report_effect bool
+
+// reservoirs holds a uniform sample of the execution times of each class.
+var reservoirs [2][]int64
+var reservoir_seen [2]int64
+
+var effect_rand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// the last bootstrap interval on the median difference, and when it was computed
+var median_ci [3]float64
+var median_ci_batch = -bootstrap_every
+
+// record_samples adds the execution times to the per-class reservoirs, using
+// reservoir sampling so that each of them is kept with the same probability.
+func record_samples(exec_times []int64, classes []int) {
+	for i := 0; i < number_measurements; i++ {
+		if exec_times[i] < 0 {
+			continue
+		}
+		class := classes[i]
+		reservoir_seen[class]++
+		if len(reservoirs[class]) < reservoir_size {
+			reservoirs[class] = append(reservoirs[class], exec_times[i])
+		} else if j := effect_rand.Int63n(reservoir_seen[class]); j < reservoir_size {
+			reservoirs[class][j] = exec_times[i]
+		}
+	}
+}
+
+func median(x []int64) float64 {
+	sort.Sort(Int64ToSort(x))
+	n := len(x)
+	if n%2 == 1 {
+		return float64(x[n/2])
+	}
+	return float64(x[n/2-1]+x[n/2]) / 2
+}
+
+// bootstrap_median_difference returns the median difference between the class
+// 1 and the class 0 reservoirs, and its 95% percentile bootstrap interval.
+func bootstrap_median_difference() (diff, low, high float64) {
+	var resampled [2][]int64
+	for class := 0; class < 2; class++ {
+		resampled[class] = append([]int64(nil), reservoirs[class]...)
+	}
+	diff = median(resampled[1]) - median(resampled[0])
+
+	diffs := make([]float64, bootstrap_resamples)
+	for b := range diffs {
+		for class := 0; class < 2; class++ {
+			for i := range resampled[class] {
+				resampled[class][i] = reservoirs[class][effect_rand.Intn(len(reservoirs[class]))]
+			}
+		}
+		diffs[b] = median(resampled[1]) - median(resampled[0])
+	}
+	sort.Float64s(diffs)
+	low = diffs[int(0.025*bootstrap_resamples)]
+	high = diffs[int(0.975*bootstrap_resamples)-1]
+	return
+}
+
+// effect_report prints, for the uncropped measurements, the difference of the
+// class means with its 95% confidence interval, Cohen's d and Hedges' g, and
+// the median difference with its bootstrap confidence interval.
+func effect_report() {
+	ctx := &tests[0]
+	n0, n1 := ctx.n[0], ctx.n[1]
+	if n0 < 2 || n1 < 2 {
+		return
+	}
+	v0 := ctx.m2[0] / (n0 - 1)
+	v1 := ctx.m2[1] / (n1 - 1)
+
+	// the measurements are numerous enough for the normal approximation.
+	diff := ctx.mean[1] - ctx.mean[0]
+	se := math.Sqrt(v0/n0 + v1/n1)
+
+	pooled := math.Sqrt(((n0-1)*v0 + (n1-1)*v1) / (n0 + n1 - 2))
+	d := diff / pooled
+	g := d * (1 - 3/(4*(n0+n1)-9))
+
+	if batch_count-median_ci_batch >= bootstrap_every {
+		median_ci[0], median_ci[1], median_ci[2] = bootstrap_median_difference()
+		median_ci_batch = batch_count
+	}
+
+	fmt.Printf("      mean diff: %+.2f ns [95%% CI %+.2f, %+.2f], Cohen's d: %+.4f, Hedges' g: %+.4f, median diff: %+.1f ns [95%% CI %+.1f, %+.1f]\n",
+		diff, diff-z_95*se, diff+z_95*se, d, g, median_ci[0], median_ci[1], median_ci[2])
+}
@@ -0,0 +1,68 @@
+package dudect
+
+import (
+	"fmt"
+	"math"
+	"runtime"
+	"runtime/debug"
+)
+
+// disable_gc turns the garbage collector off while a batch is being measured,
+// a collection being forced between batches instead, so that a GC cycle does
+// not inflate the timings of whichever class happens to be running.
+var disable_gc bool
+
+// measure_allocs enables an extra pass on each batch, outside of the timed
+// region, counting the allocations done by each computation.
+var measure_allocs bool
+
+// alloc_tests holds a t-test on the number of allocations and one on the
+// number of allocated bytes per computation.
+var alloc_tests [2]t_ctx
+
+// stop_gc forces a collection and then disables the garbage collector,
+// returning the previous setting which must be given to restart_gc.
+func stop_gc() int {
+	runtime.GC()
+	return debug.SetGCPercent(-1)
+}
+
+func restart_gc(percent int) {
+	debug.SetGCPercent(percent)
+}
+
+// measure_allocations runs the computation on each input and pushes the
+// number of allocations and of allocated bytes it required to alloc_tests.
+func measure_allocations(input_data [][]byte, classes []int) {
+	var before, after runtime.MemStats
+	for i := range input_data {
+		runtime.ReadMemStats(&before)
+		result_sink = target.DoOneComputation(input_data[i])
+		runtime.ReadMemStats(&after)
+
+		t_push(&alloc_tests[0], float64(after.Mallocs-before.Mallocs), classes[i])
+		t_push(&alloc_tests[1], float64(after.TotalAlloc-before.TotalAlloc), classes[i])
+	}
+}
+
+// alloc_t_compute is t_compute, except that two classes allocating the exact
+// same amount every time are reported with a t-value of 0 instead of NaN.
+func alloc_t_compute(ctx *t_ctx) float64 {
+	if ctx.mean[0] == ctx.mean[1] {
+		return 0
+	}
+	return t_compute(ctx)
+}
+
+func alloc_report() {
+	t_allocs := alloc_t_compute(&alloc_tests[0])
+	fmt.Printf("      allocs: %.2f (%.0f B) vs %.2f (%.0f B) per call, t(allocs): %+7.2f.",
+		alloc_tests[0].mean[0], alloc_tests[1].mean[0],
+		alloc_tests[0].mean[1], alloc_tests[1].mean[1],
+		t_allocs)
+	if math.Abs(t_allocs) > t_threshold_moderate {
+		fmt.Printf(" Allocations depend on the class.\n")
+	} else {
+		fmt.Printf(" No allocation leak found.\n")
+	}
+}
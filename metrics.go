@@ -0,0 +1,148 @@
+package dudect
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"strings"
+)
+
+// metrics_addr is the address of the metrics server, if not empty.
+var metrics_addr string
+
+type test_state struct {
+	Index        int        `json:"index"`
+	Kind         string     `json:"kind"`
+	Percentile   float64    `json:"percentile,omitempty"`
+	Measurements [2]float64 `json:"measurements"`
+	Mean         [2]float64 `json:"mean"`
+	T            *float64   `json:"t"`
+	Tau          *float64   `json:"tau"`
+}
+
+type state struct {
+	Measurements float64      `json:"measurements"`
+	MaxTest      int          `json:"max_test"`
+	MaxT         *float64     `json:"max_t"`
+	MaxTau       *float64     `json:"max_tau"`
+	Verdict      string       `json:"verdict"`
+	Batches      int          `json:"batches"`
+	DriftZ       *float64     `json:"drift_z"`
+	Tests        []test_state `json:"tests"`
+}
+
+// finite returns nil for the values JSON cannot represent.
+func finite(x float64) *float64 {
+	if math.IsNaN(x) || math.IsInf(x, 0) {
+		return nil
+	}
+	return &x
+}
+
+func test_kind(i int) (kind string, perc float64) {
+	switch {
+	case i == 0:
+		return "first_order", 0
+	case i <= number_percentiles:
+		return "cropped", 100 * (1 - math.Pow(0.5, float64(10*i)/float64(number_percentiles)))
+	}
+	return "second_order", 0
+}
+
+// snapshot returns the current state of the statistics.
+func snapshot() state {
+	stats_mutex.Lock()
+	defer stats_mutex.Unlock()
+
+	s := state{Measurements: measurements(), Batches: batch_count}
+	for i := range tests {
+		ctx := &tests[i]
+		t := math.Abs(t_compute(ctx))
+		ts := test_state{Index: i, Measurements: ctx.n, Mean: ctx.mean, T: finite(t), Tau: finite(t / (ctx.n[0] + ctx.n[1]))}
+		ts.Kind, ts.Percentile = test_kind(i)
+		s.Tests = append(s.Tests, ts)
+	}
+	if s.Measurements >= enough_measurements {
+		s.MaxTest = max_test()
+		s.MaxT = s.Tests[s.MaxTest].T
+		s.MaxTau = s.Tests[s.MaxTest].Tau
+		if s.MaxT != nil {
+			s.Verdict = verdict(*s.MaxT)
+		}
+	} else {
+		s.Verdict = "Not enough measurements."
+	}
+	if len(blocks) >= drift_min_blocks {
+		s.DriftZ = finite(drift_z())
+	}
+	return s
+}
+
+func serve_state(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(snapshot())
+}
+
+// serve_prometheus exposes the state in the Prometheus text exposition format.
+func serve_prometheus(w http.ResponseWriter, r *http.Request) {
+	s := snapshot()
+	var b strings.Builder
+	metric := func(name, kind, help string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	value := func(name, labels string, x *float64) {
+		if x != nil {
+			fmt.Fprintf(&b, "%s%s %g\n", name, labels, *x)
+		}
+	}
+
+	metric("dudect_measurements_total", "counter", "Number of measurements taken.")
+	value("dudect_measurements_total", "", &s.Measurements)
+	metric("dudect_max_t", "gauge", "Maximum absolute t-value over all the tests.")
+	value("dudect_max_t", "", s.MaxT)
+	metric("dudect_max_tau", "gauge", "t-value of the max test normalized by its number of measurements.")
+	value("dudect_max_tau", "", s.MaxTau)
+	metric("dudect_verdict", "gauge", "Current verdict, set to 1.")
+	one := 1.0
+	value("dudect_verdict", fmt.Sprintf("{verdict=%q}", s.Verdict), &one)
+	metric("dudect_drift_z", "gauge", "Mann-Kendall statistic of the per-batch class-mean differences.")
+	value("dudect_drift_z", "", s.DriftZ)
+
+	metric("dudect_t", "gauge", "Absolute t-value of each test.")
+	for _, t := range s.Tests {
+		value("dudect_t", fmt.Sprintf("{test=\"%d\",kind=%q}", t.Index, t.Kind), t.T)
+	}
+	metric("dudect_test_measurements", "gauge", "Number of measurements of each class in each test.")
+	for _, t := range s.Tests {
+		for class := 0; class < 2; class++ {
+			value("dudect_test_measurements", fmt.Sprintf("{test=\"%d\",kind=%q,class=\"%d\"}", t.Index, t.Kind, class), &t.Measurements[class])
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprint(w, b.String())
+}
+
+// serve_metrics serves the state as JSON on /state, in the Prometheus format
+// on /metrics and pprof on /debug/pprof/, returning the server, which must be
+// shut down at the end of the run, and the address it listens on.
+func serve_metrics(addr string) (*http.Server, net.Addr, error) {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/state", serve_state)
+	mux.HandleFunc("/metrics", serve_prometheus)
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	srv := &http.Server{Handler: mux}
+	go srv.Serve(l)
+	return srv, l.Addr(), nil
+}
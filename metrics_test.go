@@ -0,0 +1,92 @@
+package dudect
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServeState(t *testing.T) {
+	reset_statistics()
+	defer reset_statistics()
+	target = synthetic_target(200)
+	for i := 0; i < 3; i++ {
+		if err := measure_batch(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	serve_state(w, httptest.NewRequest("GET", "/state", nil))
+	var s map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"measurements", "max_test", "max_t", "max_tau", "verdict", "batches", "drift_z", "tests"} {
+		if _, ok := s[field]; !ok {
+			t.Errorf("the state lacks the %s field", field)
+		}
+	}
+	if s["measurements"] != float64(3*number_measurements) || s["batches"] != float64(3) {
+		t.Errorf("got %v measurements in %v batches, want %d in 3", s["measurements"], s["batches"], 3*number_measurements)
+	}
+	ts, _ := s["tests"].([]any)
+	if len(ts) != number_tests {
+		t.Fatalf("got %d tests, want %d", len(ts), number_tests)
+	}
+	for _, field := range []string{"index", "kind", "percentile", "measurements", "mean", "t", "tau"} {
+		if _, ok := ts[1].(map[string]any)[field]; !ok {
+			t.Errorf("the first cropped test lacks the %s field", field)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	serve_prometheus(w, httptest.NewRequest("GET", "/metrics", nil))
+	for _, metric := range []string{"dudect_measurements_total 9000\n", "dudect_max_t ", "dudect_verdict{verdict=", `dudect_t{test="0",kind="first_order"} `} {
+		if !strings.Contains(w.Body.String(), metric) {
+			t.Errorf("the metrics lack %q", metric)
+		}
+	}
+}
+
+func TestPrometheusNaN(t *testing.T) {
+	reset_statistics()
+	defer reset_statistics()
+
+	// without measurements, every t-value is NaN.
+	w := httptest.NewRecorder()
+	serve_prometheus(w, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range strings.Split(w.Body.String(), "\n") {
+		if strings.Contains(line, "NaN") || strings.HasPrefix(line, "dudect_t{") || strings.HasPrefix(line, "dudect_max_t ") {
+			t.Errorf("got the metric %q of a NaN t-value", line)
+		}
+	}
+	if !strings.Contains(w.Body.String(), "dudect_measurements_total 0\n") {
+		t.Errorf("the metrics lack the number of measurements:\n%s", w.Body.String())
+	}
+}
+
+func TestServeMetricsShutdown(t *testing.T) {
+	srv, addr, err := serve_metrics("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := http.Get("http://" + addr.String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if err := srv.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// a second run may serve its metrics on the same address.
+	srv, _, err = serve_metrics(addr.String())
+	if err != nil {
+		t.Fatalf("serving again on %s: %v", addr, err)
+	}
+	srv.Shutdown(context.Background())
+}
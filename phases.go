@@ -0,0 +1,158 @@
+package dudect
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// probing enables the probes placed by the targets, see Probe.
+var probing bool
+
+// phase_crop_samples is the number of samples a phase must have in a batch
+// for its cropping percentiles to be computed from that batch.
+const phase_crop_samples = 100
+
+// A phase is the part of a computation between two probes, or between a probe
+// and the start or the end of the computation, whose execution times are
+// t-tested separately. Like the execution times of the whole computation,
+// they are also t-tested once cropped at several percentiles, so that a phase
+// hit by preemption does not hide or fake a leak.
+type phase struct {
+	name string
+	// tests holds the t-test of the execution times, followed by one for the
+	// times cropped at each of percentiles once cropping is set.
+	tests       [1 + number_percentiles]t_ctx
+	percentiles [number_percentiles]int64
+	cropping    bool
+}
+
+type probe_tick struct {
+	name string
+	tick int64
+}
+
+type phase_sample struct {
+	phase int
+	ns    int64
+}
+
+var (
+	phases      []phase
+	phase_index = make(map[[2]string]int)
+	// probe_ticks holds the probes of the computation being measured, and
+	// phase_samples the phases of each computation of the batch.
+	probe_ticks   []probe_tick
+	phase_samples [][]phase_sample
+)
+
+// Probe marks a point of the computation being measured, e.g. the end of a
+// step of a cryptographic operation, so that the time spent between this
+// point and the previous one is t-tested as a phase of its own when the
+// -phases option is on. It does nothing otherwise.
+func Probe(name string) {
+	if !probing {
+		return
+	}
+	probe_ticks = append(probe_ticks, probe_tick{name, time.Now().UnixNano()})
+}
+
+func lookup_phase(from, to string) int {
+	key := [2]string{from, to}
+	i, ok := phase_index[key]
+	if !ok {
+		i = len(phases)
+		phases = append(phases, phase{name: from + ".." + to})
+		phase_index[key] = i
+	}
+	return i
+}
+
+// measure_phases times each computation on its input like measure, splitting
+// its execution time into phases at the probes it went through.
+func measure_phases(inputs [][]byte, exec_times []int64) {
+	if len(phase_samples) < len(inputs) {
+		phase_samples = make([][]phase_sample, len(inputs))
+	}
+	for i := range inputs {
+		probe_ticks = probe_ticks[:0]
+		start := time.Now().UnixNano()
+		result_sink = target.DoOneComputation(inputs[i])
+		end := time.Now().UnixNano()
+		exec_times[i] = end - start
+
+		samples := phase_samples[i][:0]
+		from, last := "start", start
+		for _, p := range probe_ticks {
+			samples = append(samples, phase_sample{lookup_phase(from, p.name), p.tick - last})
+			from, last = p.name, p.tick
+		}
+		phase_samples[i] = append(samples, phase_sample{lookup_phase(from, "end"), end - last})
+	}
+}
+
+// record_phases updates the t-tests of the phases of a batch, computing the
+// cropping percentiles of the phases which have enough samples for the first
+// time.
+func record_phases(classes []int) error {
+	batch := make(map[int][]int64)
+	for i := range classes {
+		for _, s := range phase_samples[i] {
+			if !phases[s.phase].cropping {
+				batch[s.phase] = append(batch[s.phase], s.ns)
+			}
+		}
+	}
+	for i, ns := range batch {
+		if len(ns) < phase_crop_samples {
+			continue
+		}
+		p, err := percentiles_of(ns)
+		if err != nil {
+			return err
+		}
+		phases[i].percentiles, phases[i].cropping = p, true
+	}
+
+	for i, class := range classes {
+		for _, s := range phase_samples[i] {
+			ph := &phases[s.phase]
+			t_push(&ph.tests[0], float64(s.ns), class)
+			if !ph.cropping {
+				continue
+			}
+			for crop := range ph.percentiles {
+				if s.ns < ph.percentiles[crop] {
+					t_push(&ph.tests[crop+1], float64(s.ns), class)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// max_t returns the largest t-value of the tests of the phase.
+func (ph *phase) max_t() float64 {
+	return t_compute(&ph.tests[max_test_of(ph.tests[:])])
+}
+
+// phase_report prints the mean execution time and the t-value of each phase,
+// along with the phase carrying the leak, if any.
+func phase_report() {
+	leak, leak_t := -1, 0.0
+	for i := range phases {
+		ctx := &phases[i].tests[0]
+		if ctx.n[0] < 2 || ctx.n[1] < 2 {
+			continue
+		}
+		t := phases[i].max_t()
+		mean := (ctx.mean[0]*ctx.n[0] + ctx.mean[1]*ctx.n[1]) / (ctx.n[0] + ctx.n[1])
+		fmt.Printf("  phase %-30s %12.1f ns, max t: %+7.2f\n", phases[i].name, mean, t)
+		if math.Abs(t) > leak_t {
+			leak, leak_t = i, math.Abs(t)
+		}
+	}
+	if leak_t > t_threshold_moderate {
+		fmt.Printf("  the leak is carried by the %s phase.\n", phases[leak].name)
+	}
+}
@@ -0,0 +1,57 @@
+package dudect
+
+import (
+	"math"
+	"testing"
+)
+
+func TestPhases(t *testing.T) {
+	reset_statistics()
+	defer reset_statistics()
+	probing = true
+	defer func() { probing = false }()
+
+	// only the second phase depends on the class, and one computation out of
+	// 16 is slowed down regardless of its class, like a preempted one would.
+	leaky := synthetic_target(0)
+	calls := 0
+	leaky.DoOneComputation = func(data []byte) []byte {
+		data[1] = spin(200)
+		Probe("constant")
+		if calls++; calls%16 == 0 {
+			data[1] += spin(100000)
+		}
+		data[1] += spin(200 + 1000*int(data[0]))
+		Probe("leaky")
+		return data
+	}
+	target = leaky
+	for i := 0; i < 5; i++ {
+		if err := measure_batch(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want := map[string]bool{"start..constant": false, "constant..leaky": true, "leaky..end": false}
+	if len(phases) != len(want) {
+		t.Fatalf("got %d phases, want %d", len(phases), len(want))
+	}
+	for i := range phases {
+		ph := &phases[i]
+		leaks, ok := want[ph.name]
+		if !ok {
+			t.Errorf("unexpected phase %s", ph.name)
+			continue
+		}
+		if n := ph.tests[0].n[0] + ph.tests[0].n[1]; n != 5*number_measurements {
+			t.Errorf("phase %s holds %.0f measurements, want %d", ph.name, n, 5*number_measurements)
+		}
+		if !ph.cropping {
+			t.Errorf("phase %s has no cropping percentiles", ph.name)
+		}
+		if max_t := math.Abs(ph.max_t()); leaks && max_t <= t_threshold_moderate {
+			t.Errorf("the leak of phase %s went undetected: max t = %.2f, uncropped t = %.2f",
+				ph.name, max_t, t_compute(&ph.tests[0]))
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package dudect
+
+import (
+	"flag"
+	"fmt"
+	"math"
+	"time"
+)
+
+// default_alpha is the false positive rate of a two-sided test rejecting the
+// null hypothesis when |t| > t_threshold_moderate.
+var default_alpha = math.Erfc(t_threshold_moderate / math.Sqrt2)
+
+// normal_quantile is the inverse of the standard normal distribution function.
+func normal_quantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+// plan_measurements returns the number of measurements needed in each class
+// for Welch's t-test to detect a difference delta between the class means,
+// given the variances of both classes, with the false positive rate alpha
+// and the given power.
+func plan_measurements(v0, v1, delta, alpha, power float64) float64 {
+	z := normal_quantile(1-alpha/2) + normal_quantile(power)
+	return math.Ceil(z * z * (v0 + v1) / (delta * delta))
+}
+
+// plan_main implements the plan subcommand, which estimates the number of
+// measurements and the time needed to detect a given effect, either from a
+// pilot run of the target or from a trace file.
+func plan_main(args []string) error {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	pilot := fs.Int("pilot", 10, "number of batches measured by the pilot run")
+	trace := fs.String("trace", "", "estimate from the given trace file instead of a pilot run")
+	delta := fs.Float64("delta", 1, "difference of the class means to detect, in nanoseconds")
+	cohen_d := fs.Float64("d", 0, "effect size to detect as Cohen's d, overrides -delta")
+	alpha := fs.Float64("alpha", default_alpha, "false positive rate")
+	power := fs.Float64("power", 0.9, "probability to detect the effect")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *alpha <= 0 || *alpha >= 1 || *power <= 0 || *power >= 1 {
+		return fmt.Errorf("plan: alpha and power must be in (0, 1)")
+	}
+	if *trace == "" && *pilot < 1 {
+		return fmt.Errorf("plan: -pilot must be at least 1, got %d", *pilot)
+	}
+
+	var ctx t_ctx
+	var per_measurement time.Duration
+	var source string
+	if *trace != "" {
+		err := read_trace(*trace, func(class int, exec_time int64) error {
+			if exec_time < 0 {
+				return nil
+			}
+			t_push(&ctx, float64(exec_time), class)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		// only the timed region is known from a trace.
+		per_measurement = time.Duration((ctx.mean[0]*ctx.n[0] + ctx.mean[1]*ctx.n[1]) / (ctx.n[0] + ctx.n[1]))
+		source = *trace + ", timed region only"
+	} else {
+		t, err := LookupTarget(fs.Args())
+		if err != nil {
+			return err
+		}
+		if t.Close != nil {
+			defer t.Close()
+		}
+		target = t
+		start := time.Now()
+		for i := 0; i < *pilot; i++ {
+			if err := measure_batch(); err != nil {
+				return err
+			}
+		}
+		ctx = tests[0]
+		per_measurement = time.Since(start) / time.Duration(*pilot*number_measurements)
+		source = fmt.Sprintf("pilot run of %d batches of %s", *pilot, t.Name)
+	}
+	if ctx.n[0] < 2 || ctx.n[1] < 2 {
+		return fmt.Errorf("plan: not enough measurements in %s", source)
+	}
+
+	v0 := ctx.m2[0] / (ctx.n[0] - 1)
+	v1 := ctx.m2[1] / (ctx.n[1] - 1)
+	if *cohen_d != 0 {
+		*delta = *cohen_d * math.Sqrt((v0+v1)/2)
+	}
+	n := plan_measurements(v0, v1, *delta, *alpha, *power)
+	total := time.Duration(2 * n * float64(per_measurement))
+
+	fmt.Printf("plan: from %.0f measurements (%s): std dev %.1f ns (class 0) and %.1f ns (class 1), %v per measurement.\n",
+		ctx.n[0]+ctx.n[1], source, math.Sqrt(v0), math.Sqrt(v1), per_measurement)
+	fmt.Printf("plan: to detect a difference of %.2f ns with power %.2f at a false positive rate of %.2e:\n",
+		*delta, *power, *alpha)
+	fmt.Printf("      %.3g measurements per class, %.3g in total, about %v.\n",
+		n, 2*n, total.Round(time.Millisecond))
+	return nil
+}
@@ -0,0 +1,408 @@
+package dudect
+
+import (
+	"flag"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"os"
+	"strings"
+
+	"gonum.org/v1/plot"
+	"gonum.org/v1/plot/plotter"
+	"gonum.org/v1/plot/vg"
+)
+
+const plot_every = 32 // batches between two live plots
+const histogram_bins = 100
+
+// chart_width and chart_height are the size of the SVG and PNG plots.
+const chart_width, chart_height = 6 * vg.Inch, 4 * vg.Inch
+
+const ascii_width = 64
+const ascii_height = 16
+
+// plot_prefix is the prefix of the SVG and PNG files written during a run, if not
+// empty, and plot_ascii enables the rendering of the plots in the terminal.
+var plot_prefix string
+var plot_ascii bool
+
+const histogram_percentile = 0.99
+
+// histogram accumulates the execution times of each class in bins spanning
+// the range of the first batch, up to its histogram_percentile percentile.
+type histogram struct {
+	low, width float64
+	counts     [2][histogram_bins]float64
+	total      [2]float64 // including the times out of the bins range
+}
+
+var live_histogram *histogram
+
+func new_histogram(low, high float64) *histogram {
+	if high <= low {
+		high = low + histogram_bins
+	}
+	return &histogram{low: low, width: (high - low) / histogram_bins}
+}
+
+func (h *histogram) push(x float64, class int) {
+	h.total[class]++
+	i := int((x - h.low) / h.width)
+	if i < 0 || i >= histogram_bins {
+		return
+	}
+	h.counts[class][i]++
+}
+
+type t_point struct {
+	measurements, t float64
+}
+
+// t_history holds the max t-value after each batch.
+var t_history []t_point
+
+// record_plot_data updates the live histogram and the history of the max
+// t-value with a batch, it must be called after update_statistics.
+func record_plot_data(exec_times []int64, classes []int) error {
+	if live_histogram == nil {
+		sorted := append([]int64(nil), exec_times...)
+		high, err := percentile(sorted, histogram_percentile)
+		if err != nil {
+			return err
+		}
+		low := sorted[0]
+		for _, x := range sorted {
+			if x >= 0 {
+				low = x
+				break
+			}
+		}
+		live_histogram = new_histogram(float64(low), float64(high))
+	}
+	for i := 0; i < number_measurements; i++ {
+		if exec_times[i] >= 0 {
+			live_histogram.push(float64(exec_times[i]), classes[i])
+		}
+	}
+
+	mt := max_test()
+	t_history = append(t_history, t_point{
+		measurements: tests[0].n[0] + tests[0].n[1],
+		t:            math.Abs(t_compute(&tests[mt])),
+	})
+	return nil
+}
+
+type series struct {
+	name   string
+	marker byte        // used by the ASCII rendering
+	color  color.Color // used by the SVG and PNG renderings
+	x, y   []float64
+}
+
+type chart struct {
+	name                    string // used in the file names
+	title, x_label, y_label string
+	series                  []series
+}
+
+var (
+	class_colors    = [2]color.Color{color.RGBA{0x1f, 0x77, 0xb4, 0xff}, color.RGBA{0xd6, 0x27, 0x28, 0xff}}
+	t_color         = color.RGBA{0x2c, 0xa0, 0x2c, 0xff}
+	threshold_color = color.RGBA{0x7f, 0x7f, 0x7f, 0xff}
+)
+
+// histogram_charts returns the per-class histograms, normalized so that they
+// can be compared, and the empirical distribution functions.
+func histogram_charts(h *histogram) (hist, cdf chart) {
+	hist = chart{name: "histogram", title: "Execution times per class", x_label: "time (ns)", y_label: "density"}
+	cdf = chart{name: "cdf", title: "Empirical CDF per class", x_label: "time (ns)", y_label: "P(time < x)"}
+	for class := 0; class < 2; class++ {
+		name := fmt.Sprintf("class %d", class)
+		hs := series{name: name, marker: byte('0' + class), color: class_colors[class]}
+		cs := hs
+		cumulated := 0.0
+		for i := 0; i < histogram_bins; i++ {
+			x := h.low + float64(i)*h.width
+			cumulated += h.counts[class][i]
+			hs.x = append(hs.x, x)
+			hs.y = append(hs.y, h.counts[class][i]/math.Max(h.total[class], 1))
+			cs.x = append(cs.x, x+h.width)
+			cs.y = append(cs.y, cumulated/math.Max(h.total[class], 1))
+		}
+		hist.series = append(hist.series, hs)
+		cdf.series = append(cdf.series, cs)
+	}
+	return
+}
+
+func threshold_series(x0, x1 float64) series {
+	return series{name: fmt.Sprintf("t = %d", t_threshold_moderate), marker: '-', color: threshold_color,
+		x: []float64{x0, x1}, y: []float64{t_threshold_moderate, t_threshold_moderate}}
+}
+
+// t_count_chart plots the max t-value against the number of measurements.
+func t_count_chart() chart {
+	s := series{name: "max |t|", marker: '*', color: t_color}
+	for _, p := range t_history {
+		s.x = append(s.x, p.measurements)
+		s.y = append(s.y, p.t)
+	}
+	c := chart{name: "t-count", title: "Max t-value per number of measurements", x_label: "measurements", y_label: "|t|"}
+	c.series = append(c.series, s)
+	if len(s.x) > 0 {
+		c.series = append(c.series, threshold_series(s.x[0], s.x[len(s.x)-1]))
+	}
+	return c
+}
+
+// t_crop_chart plots the t-value of the cropped tests against their percentile.
+func t_crop_chart() chart {
+	s := series{name: "|t|", marker: '*', color: t_color}
+	for i := 0; i < number_percentiles; i++ {
+		ctx := &tests[i+1]
+		if ctx.n[0] < 2 || ctx.n[1] < 2 {
+			continue
+		}
+		s.x = append(s.x, 100*(1-math.Pow(0.5, float64(10*(i+1))/float64(number_percentiles))))
+		s.y = append(s.y, math.Abs(t_compute(ctx)))
+	}
+	c := chart{name: "t-crop", title: "t-value per cropping percentile", x_label: "percentile (%)", y_label: "|t|"}
+	c.series = append(c.series, s)
+	if len(s.x) > 0 {
+		c.series = append(c.series, threshold_series(s.x[0], s.x[len(s.x)-1]))
+	}
+	return c
+}
+
+// bounds returns the range of the chart, always including y = 0.
+func (c *chart) bounds() (x0, x1, y0, y1 float64) {
+	x0, x1, y0, y1 = math.Inf(1), math.Inf(-1), 0, math.Inf(-1)
+	for _, s := range c.series {
+		for i := range s.x {
+			if math.IsNaN(s.y[i]) || math.IsInf(s.y[i], 0) {
+				continue
+			}
+			x0, x1 = math.Min(x0, s.x[i]), math.Max(x1, s.x[i])
+			y0, y1 = math.Min(y0, s.y[i]), math.Max(y1, s.y[i])
+		}
+	}
+	if x1 < x0 {
+		x0, x1 = 0, 1
+	}
+	if x1 == x0 {
+		x1 = x0 + 1
+	}
+	if y1 <= y0 {
+		y1 = y0 + 1
+	}
+	return
+}
+
+// plot lays the chart out with gonum/plot, which renders it in every format.
+func (c *chart) plot() (*plot.Plot, error) {
+	p := plot.New()
+	p.Title.Text = c.title
+	p.X.Label.Text = c.x_label
+	p.Y.Label.Text = c.y_label
+	p.X.Min, p.X.Max, p.Y.Min, p.Y.Max = c.bounds()
+	p.Legend.Top = true
+	p.Add(plotter.NewGrid())
+	for _, s := range c.series {
+		points := make(plotter.XYs, 0, len(s.x))
+		for i := range s.x {
+			if !math.IsNaN(s.y[i]) && !math.IsInf(s.y[i], 0) {
+				points = append(points, plotter.XY{X: s.x[i], Y: s.y[i]})
+			}
+		}
+		line, err := plotter.NewLine(points)
+		if err != nil {
+			return nil, err
+		}
+		line.Color = s.color
+		p.Add(line)
+		p.Legend.Add(s.name, line)
+	}
+	return p, nil
+}
+
+// render writes the chart to w in the given format, "svg" or "png".
+func (c *chart) render(w io.Writer, format string) error {
+	p, err := c.plot()
+	if err != nil {
+		return err
+	}
+	wt, err := p.WriterTo(chart_width, chart_height, format)
+	if err != nil {
+		return err
+	}
+	_, err = wt.WriteTo(w)
+	return err
+}
+
+// ascii renders the chart on a grid of characters, each series being drawn
+// with its marker, interpolating between its points. Overlapping series are
+// drawn with a '+'.
+func (c *chart) ascii(w io.Writer) {
+	x0, x1, y0, y1 := c.bounds()
+	var grid [ascii_height][ascii_width]byte
+	for row := range grid {
+		for col := range grid[row] {
+			grid[row][col] = ' '
+		}
+	}
+	to_col := func(x float64) float64 { return (x - x0) / (x1 - x0) * (ascii_width - 1) }
+	to_row := func(y float64) float64 { return ascii_height - 1 - (y-y0)/(y1-y0)*(ascii_height-1) }
+	plot := func(row, col float64, marker byte) {
+		cell := &grid[int(math.Round(row))][int(math.Round(col))]
+		if *cell != ' ' && *cell != marker {
+			marker = '+'
+		}
+		*cell = marker
+	}
+	for _, s := range c.series {
+		prev := -1
+		for i := range s.x {
+			if math.IsNaN(s.y[i]) || math.IsInf(s.y[i], 0) {
+				continue
+			}
+			col, row := to_col(s.x[i]), to_row(s.y[i])
+			plot(row, col, s.marker)
+			if prev >= 0 {
+				prev_col, prev_row := to_col(s.x[prev]), to_row(s.y[prev])
+				for c := math.Ceil(prev_col); c < col; c++ {
+					r := prev_row + (row-prev_row)*(c-prev_col)/(col-prev_col)
+					plot(r, c, s.marker)
+				}
+			}
+			prev = i
+		}
+	}
+
+	fmt.Fprintf(w, "%s\n", c.title)
+	for row := range grid {
+		label := ""
+		switch row {
+		case 0:
+			label = fmt.Sprintf("%.3g", y1)
+		case ascii_height - 1:
+			label = fmt.Sprintf("%.3g", y0)
+		}
+		fmt.Fprintf(w, "%10s |%s\n", label, grid[row][:])
+	}
+	fmt.Fprintf(w, "%10s +%s\n", "", strings.Repeat("-", ascii_width))
+	fmt.Fprintf(w, "%10s  %-*.4g%*.4g\n", "", ascii_width/2, x0, ascii_width/2, x1)
+	var legend []string
+	for _, s := range c.series {
+		legend = append(legend, fmt.Sprintf("%c: %s", s.marker, s.name))
+	}
+	fmt.Fprintf(w, "%10s  %s, +: overlap, x: %s, y: %s\n", "", strings.Join(legend, ", "), c.x_label, c.y_label)
+}
+
+func all_charts() []chart {
+	var charts []chart
+	if live_histogram != nil {
+		hist, cdf := histogram_charts(live_histogram)
+		charts = append(charts, hist, cdf)
+	}
+	return append(charts, t_count_chart(), t_crop_chart())
+}
+
+// write_plots writes each chart to the prefix-name.svg and prefix-name.png files.
+func write_plots(prefix string) error {
+	for _, c := range all_charts() {
+		for _, format := range []string{"svg", "png"} {
+			if err := write_chart(prefix+"-"+c.name+"."+format, c, format); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func write_chart(path string, c chart, format string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = c.render(f, format)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func print_plots(w io.Writer) {
+	for _, c := range all_charts() {
+		c.ascii(w)
+		fmt.Fprintln(w)
+	}
+}
+
+// live_plots renders the plots requested for the run every plot_every batches.
+func live_plots() error {
+	if len(t_history)%plot_every != 1 {
+		return nil
+	}
+	if plot_ascii {
+		print_plots(os.Stdout)
+	}
+	if plot_prefix != "" {
+		return write_plots(plot_prefix)
+	}
+	return nil
+}
+
+// plot_main implements the plot subcommand, which replays a trace file through
+// the statistics, batch per batch, and plots the result.
+func plot_main(args []string) error {
+	fs := flag.NewFlagSet("plot", flag.ContinueOnError)
+	prefix := fs.String("o", "dudect", "prefix of the SVG and PNG files to write, none if empty")
+	ascii := fs.Bool("ascii", false, "render the plots in the terminal")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return fmt.Errorf("plot: expected a single trace file")
+	}
+
+	exec_times := make([]int64, 0, number_measurements)
+	classes := make([]int, 0, number_measurements)
+	replay := func() error {
+		if percentiles[number_percentiles-1] == 0 {
+			if err := prepare_percentiles(exec_times); err != nil {
+				return err
+			}
+		}
+		update_statistics(exec_times, classes)
+		if err := record_plot_data(exec_times, classes); err != nil {
+			return err
+		}
+		exec_times, classes = exec_times[:0], classes[:0]
+		return nil
+	}
+	err := read_trace(fs.Arg(0), func(class int, exec_time int64) error {
+		exec_times = append(exec_times, exec_time)
+		classes = append(classes, class)
+		if len(exec_times) == number_measurements {
+			return replay()
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(t_history) == 0 {
+		return fmt.Errorf("plot: %s holds less than a batch of %d measurements", fs.Arg(0), number_measurements)
+	}
+
+	if *ascii {
+		print_plots(os.Stdout)
+	}
+	if *prefix != "" {
+		return write_plots(*prefix)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package dudect
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+)
+
+func TestChartRender(t *testing.T) {
+	c := chart{name: "test", title: "Test chart", x_label: "x", y_label: "y", series: []series{
+		{name: "class 0", color: class_colors[0], x: []float64{0, 1, 2}, y: []float64{0, 2, 1}},
+	}}
+
+	var b bytes.Buffer
+	if err := c.render(&b, "svg"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b.Bytes(), []byte("<svg")) || !bytes.Contains(b.Bytes(), []byte("Test chart")) {
+		t.Errorf("the SVG plot lacks its title:\n%s", b.Bytes())
+	}
+
+	b.Reset()
+	if err := c.render(&b, "png"); err != nil {
+		t.Fatal(err)
+	}
+	img, err := png.Decode(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the PNG plots are rendered at 96 dpi.
+	size := img.Bounds().Size()
+	if size.X != 6*96 || size.Y != 4*96 {
+		t.Fatalf("got a %v image, want 576x384", size)
+	}
+	// the series is the only blue part of the plot, the rest being gray.
+	drawn := 0
+	for y := 0; y < size.Y; y++ {
+		for x := 0; x < size.X; x++ {
+			if r, _, b, _ := img.At(x, y).RGBA(); b > r+0x2000 {
+				drawn++
+			}
+		}
+	}
+	if drawn < 100 {
+		t.Errorf("the series was drawn on %d pixels only", drawn)
+	}
+}
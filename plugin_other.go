@@ -0,0 +1,9 @@
+//go:build !((linux || darwin || freebsd) && cgo)
+
+package dudect
+
+import "fmt"
+
+func load_plugin(path string) error {
+	return fmt.Errorf("%w: plugins are not supported on this platform, use dudect scaffold instead", ErrPlugin)
+}
@@ -0,0 +1,16 @@
+//go:build (linux || darwin || freebsd) && cgo
+
+package dudect
+
+import (
+	"fmt"
+	"plugin"
+)
+
+// load_plugin opens a Go plugin, whose init functions register its targets.
+func load_plugin(path string) error {
+	if _, err := plugin.Open(path); err != nil {
+		return fmt.Errorf("%w: %v", ErrPlugin, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package dudect
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// calibration_package registers the target swept by the calibrate command,
+// which the generated main package always imports.
+const calibration_package = "github.com/AnomalRoil/go-dudect/targets/calibration"
+
+var scaffold_template = template.Must(template.New("main").Funcs(template.FuncMap{"join": strings.Join}).Parse(`// Code generated by dudect scaffold; DO NOT EDIT.
+
+// Command dudect assesses whether the targets registered by {{join .Packages ", "}} run in constant time.
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/AnomalRoil/go-dudect"
+	_ "{{.Calibration}}"
+{{range .Packages}}	_ "{{.}}"
+{{end}})
+
+func main() {
+	if err := dudect.Main(os.Args[1:]); err != nil {
+		fmt.Println("error:", err)
+		os.Exit(1)
+	}
+}
+`))
+
+// current_package returns the import path of the package in the current
+// directory, which is the package calling go generate.
+func current_package() (string, error) {
+	out, err := exec.Command("go", "list", "-f", "{{.ImportPath}}", ".").Output()
+	if err != nil {
+		return "", fmt.Errorf("scaffold: cannot find the current package, use -pkg: %v", err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// scaffold_main implements the scaffold subcommand, which writes a main
+// package wrapping the given packages registering targets, so that they can
+// be assessed from their own repositories. It is meant to be used with
+// go generate, e.g.:
+//
+//	//go:generate go run github.com/AnomalRoil/go-dudect/cmd/dudect scaffold -o ../cmd/dudect
+func scaffold_main(args []string) error {
+	fs := flag.NewFlagSet("scaffold", flag.ContinueOnError)
+	out := fs.String("o", "dudect", "directory in which the main package is written")
+	pkgs := fs.String("pkg", "", "comma separated import paths of the packages registering targets, the current one if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var packages []string
+	if *pkgs == "" {
+		pkg, err := current_package()
+		if err != nil {
+			return err
+		}
+		packages = append(packages, pkg)
+	} else {
+		for _, pkg := range strings.Split(*pkgs, ",") {
+			if pkg = strings.TrimSpace(pkg); pkg != calibration_package {
+				packages = append(packages, pkg)
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	data := struct {
+		Calibration string
+		Packages    []string
+	}{calibration_package, packages}
+	if err := scaffold_template.Execute(&b, data); err != nil {
+		return err
+	}
+	src, err := format.Source(b.Bytes())
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*out, 0755); err != nil {
+		return err
+	}
+	path := filepath.Join(*out, "main.go")
+	if err := os.WriteFile(path, src, 0644); err != nil {
+		return err
+	}
+	fmt.Println("wrote", path)
+	return nil
+}
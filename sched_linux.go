@@ -0,0 +1,176 @@
+package dudect
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+const sched_fifo = 1
+
+type sched_param struct {
+	priority int32
+}
+
+type cpu_mask [1024 / 64]uint64
+
+func (mask *cpu_mask) has(cpu int) bool {
+	return cpu >= 0 && cpu < len(mask)*64 && mask[cpu/64]&(1<<uint(cpu%64)) != 0
+}
+
+// affinity returns the affinity mask of the calling thread.
+func affinity() (mask cpu_mask, err error) {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETAFFINITY,
+		0, uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return mask, fmt.Errorf("%w: sched_getaffinity: %v", ErrIsolation, errno)
+	}
+	return mask, nil
+}
+
+func set_affinity(mask *cpu_mask) error {
+	_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETAFFINITY,
+		0, uintptr(len(mask)*8), uintptr(unsafe.Pointer(&mask[0])))
+	if errno != 0 {
+		return fmt.Errorf("%w: sched_setaffinity: %v", ErrIsolation, errno)
+	}
+	return nil
+}
+
+// isolation_cpu_of returns the CPU to isolate the measurements on: the given
+// one if it belongs to the affinity mask of the process, or the highest CPU
+// of the mask if it is negative. runtime.NumCPU counts the CPUs of the mask,
+// which need not be numbered from 0, e.g. under taskset or a cpuset cgroup.
+func isolation_cpu_of(cpu int) (int, error) {
+	mask, err := affinity()
+	if err != nil {
+		return 0, err
+	}
+	if cpu >= 0 {
+		if !mask.has(cpu) {
+			return 0, fmt.Errorf("%w: cpu %d is not in the affinity mask of the process", ErrIsolation, cpu)
+		}
+		return cpu, nil
+	}
+	for cpu = len(mask)*64 - 1; cpu >= 0; cpu-- {
+		if mask.has(cpu) {
+			return cpu, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: empty affinity mask", ErrIsolation)
+}
+
+// isolate_thread locks the measuring goroutine to its OS thread, pins that
+// thread to the given CPU, as returned by isolation_cpu_of, and raises its
+// scheduling priority to SCHED_FIFO, when permitted. The returned function
+// restores the affinity and the scheduling policy of the thread, and unlocks
+// it.
+func isolate_thread(cpu int) (restore func(), err error) {
+	runtime.LockOSThread()
+	saved_mask, err := affinity()
+	if err != nil {
+		runtime.UnlockOSThread()
+		return nil, err
+	}
+	saved_policy, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_GETSCHEDULER, 0, 0, 0)
+	var saved_param sched_param
+	if errno == 0 {
+		_, _, errno = syscall.RawSyscall(syscall.SYS_SCHED_GETPARAM, 0, uintptr(unsafe.Pointer(&saved_param)), 0)
+	}
+	if errno != 0 {
+		runtime.UnlockOSThread()
+		return nil, fmt.Errorf("%w: sched_getscheduler: %v", ErrIsolation, errno)
+	}
+
+	var mask cpu_mask
+	mask[cpu/64] |= 1 << uint(cpu%64)
+	if err := set_affinity(&mask); err != nil {
+		runtime.UnlockOSThread()
+		return nil, err
+	}
+
+	param := sched_param{priority: 1}
+	_, _, errno = syscall.RawSyscall(syscall.SYS_SCHED_SETSCHEDULER,
+		0, sched_fifo, uintptr(unsafe.Pointer(&param)))
+	fifo := errno == 0
+	if fifo {
+		fmt.Println("INFO: measuring with SCHED_FIFO scheduling.")
+	} else {
+		fmt.Printf("WARNING: could not switch to SCHED_FIFO (%v), keeping the default scheduling policy.\n", errno)
+	}
+	fmt.Printf("INFO: measurements pinned to cpu %d.\n", cpu)
+
+	return func() {
+		err := set_affinity(&saved_mask)
+		if fifo {
+			_, _, errno := syscall.RawSyscall(syscall.SYS_SCHED_SETSCHEDULER,
+				0, saved_policy, uintptr(unsafe.Pointer(&saved_param)))
+			if errno != 0 && err == nil {
+				err = fmt.Errorf("%w: sched_setscheduler: %v", ErrIsolation, errno)
+			}
+		}
+		if err != nil {
+			// the thread stays locked, so that no other goroutine runs on
+			// it, and is terminated when the goroutine exits.
+			fmt.Printf("WARNING: could not restore the measuring thread: %v\n", err)
+			return
+		}
+		runtime.UnlockOSThread()
+	}, nil
+}
+
+func read_sysfs(path string) (string, bool) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(b)), true
+}
+
+// environment_report prints the frequency governor of the given CPU and the
+// turbo state, warning when they are likely to produce noisy timings.
+func environment_report(cpu int) {
+	governor, ok := read_sysfs(fmt.Sprintf("/sys/devices/system/cpu/cpu%d/cpufreq/scaling_governor", cpu))
+	switch {
+	case !ok:
+		fmt.Println("INFO: unknown cpu frequency governor.")
+	case governor != "performance":
+		fmt.Printf("WARNING: cpu %d uses the %q frequency governor, timings may be noisy: consider \"performance\".\n", cpu, governor)
+	default:
+		fmt.Printf("INFO: cpu %d uses the %q frequency governor.\n", cpu, governor)
+	}
+
+	// intel_pstate exposes no_turbo, other drivers expose boost.
+	if no_turbo, ok := read_sysfs("/sys/devices/system/cpu/intel_pstate/no_turbo"); ok {
+		if no_turbo == "0" {
+			fmt.Println("WARNING: turbo boost is enabled, timings may be noisy.")
+		} else {
+			fmt.Println("INFO: turbo boost is disabled.")
+		}
+	} else if boost, ok := read_sysfs("/sys/devices/system/cpu/cpufreq/boost"); ok {
+		if boost == "1" {
+			fmt.Println("WARNING: cpu frequency boost is enabled, timings may be noisy.")
+		} else {
+			fmt.Println("INFO: cpu frequency boost is disabled.")
+		}
+	} else {
+		fmt.Println("INFO: unknown turbo boost state.")
+	}
+}
+
+// cpu_model returns the model name of the first CPU, if known.
+func cpu_model() string {
+	b, err := os.ReadFile("/proc/cpuinfo")
+	if err != nil {
+		return ""
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok && strings.TrimSpace(key) == "model name" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,44 @@
+package dudect
+
+import (
+	"runtime"
+	"syscall"
+	"testing"
+)
+
+func TestIsolateThreadRestore(t *testing.T) {
+	// the lock is nested, so that the thread is the same after the restore.
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	scheduler := func() uintptr {
+		policy, _, _ := syscall.RawSyscall(syscall.SYS_SCHED_GETSCHEDULER, 0, 0, 0)
+		return policy
+	}
+	mask, err := affinity()
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy := scheduler()
+
+	cpu, err := isolation_cpu_of(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	restore, err := isolate_thread(cpu)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want cpu_mask
+	want[cpu/64] |= 1 << uint(cpu%64)
+	if pinned, err := affinity(); err != nil || pinned != want {
+		t.Errorf("the thread was not pinned to cpu %d (%v)", cpu, err)
+	}
+
+	restore()
+	if restored, err := affinity(); err != nil || restored != mask {
+		t.Errorf("the affinity of the thread was not restored (%v)", err)
+	}
+	if restored := scheduler(); restored != policy {
+		t.Errorf("the scheduling policy is %d after the restore, want %d", restored, policy)
+	}
+}
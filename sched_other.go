@@ -0,0 +1,17 @@
+//go:build !linux
+
+package dudect
+
+import "fmt"
+
+func isolation_cpu_of(cpu int) (int, error) {
+	return 0, fmt.Errorf("%w: only supported on Linux", ErrIsolation)
+}
+
+func isolate_thread(cpu int) (restore func(), err error) {
+	return nil, fmt.Errorf("%w: only supported on Linux", ErrIsolation)
+}
+
+func environment_report(cpu int) {}
+
+func cpu_model() string { return "" }
@@ -0,0 +1,142 @@
+package dudect
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	// ErrUnknownTarget is returned when no target is registered under a name.
+	ErrUnknownTarget = errors.New("dudect: unknown target")
+	// ErrPlugin is returned when a plugin cannot be loaded.
+	ErrPlugin = errors.New("dudect: cannot load plugin")
+)
+
+// A Target is a function whose execution time is assessed by dudect, along
+// with the inputs of the two classes it is measured on.
+type Target struct {
+	// Name identifies the target on the command line.
+	Name        string
+	Description string
+
+	// PrepareInputs returns n inputs and their classes, which must be 0 or 1.
+	PrepareInputs func(n int) (input_data [][]byte, classes []int, err error)
+	// DoOneComputation runs the function under study on data and returns its
+	// result, which is kept so that the computation cannot be optimized away.
+	DoOneComputation func(data []byte) []byte
+	// CheckComputation is an optional correctness check, it is run outside of
+	// the timed region with the result of DoOneComputation on every input of
+	// the first batch of a run, and on a sample of the inputs of the following
+	// batches, and must return an error if the computation misbehaved on data.
+	CheckComputation func(data, result []byte, class int) error
+
+	// Configure, if set, receives the arguments following the name of the
+	// target on the command line, before any other function is called.
+	Configure func(args []string) error
+	// Measure, if set, runs the computation like DoOneComputation but also
+	// returns its execution time in nanoseconds as measured by the target
+	// itself, which is then used instead of the time measured around the call.
+	Measure func(data []byte) (result []byte, exec_time int64)
+	// Err, if set, returns the first error met by the computations so far, as
+	// DoOneComputation and Measure cannot return one. It is called after each
+	// batch is measured, and an error stops the run before the measurements
+	// of that batch are recorded.
+	Err func() error
+	// Close, if set, releases the resources of the target at the end of a run.
+	Close func() error
+	// Seed, if set, reseeds the generator the inputs are drawn from, so that
+	// the inputs returned by PrepareInputs from then on only depend on seed.
+	Seed func(seed int64)
+	// Variants, if set, returns variations of the configured target, e.g. with
+	// different options, which compare assesses side by side when given this
+	// target alone.
+	Variants func() []*Target
+
+	// args are the arguments the target was configured with by LookupTarget.
+	args []string
+}
+
+var (
+	registry_mutex sync.Mutex
+	registry       = make(map[string]func() *Target)
+)
+
+// Register makes a target available by its name, factory returning a new
+// instance of the target on each call, so that the state of an instance, such
+// as its configuration, is not shared with the others. Register is meant to be
+// called from the init function of the package defining the target and panics
+// if the target is incomplete or if its name is already taken.
+func Register(factory func() *Target) {
+	t := factory()
+	registry_mutex.Lock()
+	defer registry_mutex.Unlock()
+	if t.Name == "" || t.PrepareInputs == nil || t.DoOneComputation == nil {
+		panic("dudect: Register of an incomplete target")
+	}
+	if _, dup := registry[t.Name]; dup {
+		panic("dudect: Register called twice for target " + t.Name)
+	}
+	registry[t.Name] = factory
+}
+
+// Lookup returns a new instance of the target registered under the given name.
+func Lookup(name string) (*Target, error) {
+	registry_mutex.Lock()
+	factory, ok := registry[name]
+	registry_mutex.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownTarget, name)
+	}
+	return factory(), nil
+}
+
+// LookupTarget returns a new instance of the target named by the first
+// argument, configured with the following ones, as done on the command line.
+// The target is closed if its configuration fails.
+func LookupTarget(args []string) (*Target, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected a target, see dudect list")
+	}
+	t, err := Lookup(args[0])
+	if err != nil {
+		return nil, err
+	}
+	t.args = args[1:]
+	if t.Configure != nil {
+		err = t.Configure(args[1:])
+	} else if len(args) > 1 {
+		err = fmt.Errorf("target %s takes no arguments, got %q", t.Name, args[1:])
+	}
+	if err != nil {
+		if t.Close != nil {
+			t.Close()
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// command_line returns the name of the target followed by the arguments it
+// was configured with.
+func (t *Target) command_line() string {
+	return strings.Join(append([]string{t.Name}, t.args...), " ")
+}
+
+// Targets returns an instance of each registered target, sorted by name.
+func Targets() []*Target {
+	registry_mutex.Lock()
+	var factories []func() *Target
+	for _, factory := range registry {
+		factories = append(factories, factory)
+	}
+	registry_mutex.Unlock()
+	var targets []*Target
+	for _, factory := range factories {
+		targets = append(targets, factory())
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Name < targets[j].Name })
+	return targets
+}
@@ -0,0 +1,111 @@
+// Package calibration provides a synthetic leak of a chosen size, which the
+// calibrate command sweeps to find the smallest leak detectable on a host: the
+// target spins for a base number of rounds on inputs of class 0 and for delta
+// more rounds on inputs of class 1, plus some noise drawn for each input.
+package calibration
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/AnomalRoil/go-dudect"
+)
+
+// calibration is an instance of the target, whose noise is drawn from the
+// noise distribution, of mean scale rounds.
+type calibration struct {
+	base  int
+	delta int
+	noise string
+	scale float64
+	rand  *rand.Rand
+}
+
+func new_calibration() *calibration {
+	return &calibration{base: 200, delta: 10, noise: "none", scale: 100, rand: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func new_target() *dudect.Target {
+	c := new_calibration()
+	return &dudect.Target{
+		Name:             "calibration",
+		Description:      "a synthetic leak of a chosen size: calibration [-delta rounds] [-base rounds] [-noise dist] [-noise-scale rounds]",
+		PrepareInputs:    c.prepare_inputs,
+		DoOneComputation: c.do_one_computation,
+		Configure:        c.configure,
+		Seed:             c.rand.Seed,
+	}
+}
+
+// spin does n rounds of a linear congruential generator, its result depending
+// on every round so that the loop cannot be optimized away.
+func spin(n int) byte {
+	x := uint64(1)
+	for i := 0; i < n; i++ {
+		x = x*6364136223846793005 + 1442695040888963407
+	}
+	return byte(x >> 56)
+}
+
+func (c *calibration) configure(args []string) error {
+	fs := flag.NewFlagSet("calibration", flag.ContinueOnError)
+	fs.IntVar(&c.delta, "delta", 10, "number of extra rounds of class 1")
+	fs.IntVar(&c.base, "base", 200, "number of rounds of both classes")
+	fs.StringVar(&c.noise, "noise", "none", "distribution of the noise added to each computation: none, uniform or exponential")
+	fs.Float64Var(&c.scale, "noise-scale", 100, "mean of the noise, in rounds")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("calibration: unexpected arguments %q", fs.Args())
+	}
+	switch c.noise {
+	case "none", "uniform", "exponential":
+	default:
+		return fmt.Errorf("calibration: unknown noise distribution %q", c.noise)
+	}
+	if c.base < 0 || c.delta < 0 || c.scale < 0 {
+		return fmt.Errorf("calibration: the rounds cannot be negative")
+	}
+	return nil
+}
+
+func (c *calibration) noise_rounds() uint32 {
+	switch c.noise {
+	case "uniform":
+		return uint32(c.rand.Float64() * 2 * c.scale)
+	case "exponential":
+		return uint32(math.Min(c.rand.ExpFloat64()*c.scale, math.MaxUint32))
+	}
+	return 0
+}
+
+// prepare_inputs draws the class and the noise of each input beforehand, so
+// that nothing but the spinning is timed: an input holds its class, its
+// number of noise rounds and a byte for the result.
+func (c *calibration) prepare_inputs(n int) (input_data [][]byte, classes []int, err error) {
+	input_data = make([][]byte, n)
+	classes = make([]int, n)
+	for i := 0; i < n; i++ {
+		classes[i] = c.rand.Intn(2)
+		data := make([]byte, 6)
+		data[0] = byte(classes[i])
+		binary.LittleEndian.PutUint32(data[1:5], c.noise_rounds())
+		input_data[i] = data
+	}
+	return
+}
+
+func (c *calibration) do_one_computation(data []byte) []byte {
+	rounds := c.base + c.delta*int(data[0]) + int(binary.LittleEndian.Uint32(data[1:5]))
+	data[5] = spin(rounds)
+	return data
+}
+
+func init() {
+	dudect.Register(new_target)
+}
@@ -0,0 +1,34 @@
+package calibration
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNoiseRounds(t *testing.T) {
+	c := new_calibration()
+	c.scale = 1000
+	for _, noise := range []string{"none", "uniform", "exponential"} {
+		c.noise = noise
+		var sum float64
+		const n = 100000
+		for i := 0; i < n; i++ {
+			sum += float64(c.noise_rounds())
+		}
+		want := c.scale
+		if noise == "none" {
+			want = 0
+		}
+		if mean := sum / n; math.Abs(mean-want) > 0.02*c.scale {
+			t.Errorf("%s noise: mean of %.1f rounds, want %.1f", noise, mean, want)
+		}
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	for _, args := range [][]string{{"-noise", "gaussian"}, {"-delta", "-1"}, {"extra"}} {
+		if err := new_calibration().configure(args); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
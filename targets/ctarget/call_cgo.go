@@ -0,0 +1,102 @@
+//go:build cgo && (linux || darwin || freebsd)
+
+package ctarget
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdint.h>
+#include <stdlib.h>
+
+typedef void (*target_fn)(uint8_t *, size_t);
+
+static void call(void *f, uint8_t *data, size_t len) {
+	((target_fn)f)(data, len);
+}
+
+static void noop(uint8_t *data, size_t len) {
+	(void)data;
+	(void)len;
+}
+
+static void *noop_fn(void) {
+	return (void *)noop;
+}
+*/
+import "C"
+
+import (
+	"errors"
+	"sort"
+	"time"
+	"unsafe"
+)
+
+// calibration_calls is the number of empty calls timed to estimate the cgo
+// overhead, whose median is kept.
+const calibration_calls = 10000
+
+// function is a C function looked up in a shared library.
+type function struct {
+	handle   unsafe.Pointer
+	ptr      unsafe.Pointer
+	overhead int64 // in nanoseconds
+}
+
+func dlerror() error {
+	return errors.New(C.GoString(C.dlerror()))
+}
+
+func open(library, name string) (*function, error) {
+	clib := C.CString(library)
+	defer C.free(unsafe.Pointer(clib))
+	handle := C.dlopen(clib, C.RTLD_NOW|C.RTLD_LOCAL)
+	if handle == nil {
+		return nil, dlerror()
+	}
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	ptr := C.dlsym(handle, cname)
+	if ptr == nil {
+		err := dlerror()
+		C.dlclose(handle)
+		return nil, err
+	}
+
+	f := &function{handle: handle, ptr: ptr}
+	f.overhead = f.calibrate()
+	return f, nil
+}
+
+// calibrate times calls to an empty C function through the same path as the
+// calls to the target function, and returns their median.
+func (f *function) calibrate() int64 {
+	noop := &function{ptr: C.noop_fn()}
+	data := make([]byte, 1)
+	times := make([]int64, calibration_calls)
+	for i := range times {
+		times[i] = noop.time(data)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	return times[len(times)/2]
+}
+
+func (f *function) call(data []byte) {
+	C.call(f.ptr, (*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data)))
+}
+
+// time returns the time taken by the call in nanoseconds, cgo overhead
+// included.
+func (f *function) time(data []byte) int64 {
+	p, n := (*C.uint8_t)(unsafe.Pointer(&data[0])), C.size_t(len(data))
+	start := time.Now()
+	C.call(f.ptr, p, n)
+	return time.Since(start).Nanoseconds()
+}
+
+func (f *function) close() error {
+	if C.dlclose(f.handle) != 0 {
+		return dlerror()
+	}
+	return nil
+}
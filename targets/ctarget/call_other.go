@@ -0,0 +1,17 @@
+//go:build !cgo || !(linux || darwin || freebsd)
+
+package ctarget
+
+import "errors"
+
+type function struct {
+	overhead int64
+}
+
+func open(library, name string) (*function, error) {
+	return nil, errors.New("calling C functions requires cgo on linux, darwin or freebsd")
+}
+
+func (f *function) call(data []byte)       {}
+func (f *function) time(data []byte) int64 { return 0 }
+func (f *function) close() error           { return nil }
@@ -0,0 +1,97 @@
+// Package ctarget assesses C functions with a
+//
+//	void f(uint8_t *data, size_t len)
+//
+// signature, loaded from a shared library and called directly through cgo, so
+// that C and Go implementations of the same primitive can be compared with the
+// same tool. The time measured around each call has the overhead of cgo,
+// calibrated with an empty C function when the target is configured, taken
+// out of it. The function may write its result into data.
+package ctarget
+
+import (
+	"flag"
+	"fmt"
+	mrand "math/rand"
+
+	"github.com/AnomalRoil/go-dudect"
+	"github.com/AnomalRoil/go-dudect/targets/internal/inputs"
+)
+
+// library is an instance of the cgo target.
+type library struct {
+	fixed_flags inputs.Fixed
+	fixed       []byte
+	rn          *mrand.Rand
+	fn          *function
+}
+
+func new_target() *dudect.Target {
+	l := &library{rn: inputs.NewRand()}
+	return &dudect.Target{
+		Name:             "cgo",
+		Description:      "a C function from a shared library, fixed vs random inputs: cgo [-size n] [-fixed hex] library function",
+		PrepareInputs:    l.prepare_inputs,
+		DoOneComputation: l.do_one_computation,
+		Configure:        l.configure,
+		Measure:          l.measure_call,
+		Close:            l.close,
+		Seed:             l.rn.Seed,
+	}
+}
+
+func (l *library) configure(args []string) error {
+	fs := flag.NewFlagSet("cgo", flag.ContinueOnError)
+	l.fixed_flags.Flags(fs)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		return fmt.Errorf("cgo: expected a shared library and a function name")
+	}
+	var err error
+	if l.fixed, err = l.fixed_flags.Value(); err != nil {
+		return fmt.Errorf("cgo: %v", err)
+	}
+	if len(l.fixed) == 0 {
+		return fmt.Errorf("cgo: the inputs cannot be empty")
+	}
+
+	if l.fn, err = open(fs.Arg(0), fs.Arg(1)); err != nil {
+		return fmt.Errorf("cgo: %v", err)
+	}
+	fmt.Printf("cgo: calling %s from %s, call overhead %d ns\n", fs.Arg(1), fs.Arg(0), l.fn.overhead)
+	return nil
+}
+
+func (l *library) close() error {
+	if l.fn == nil {
+		return nil
+	}
+	return l.fn.close()
+}
+
+// prepare_inputs returns fixed inputs for class 0 and random ones for class 1.
+func (l *library) prepare_inputs(n int) (input_data [][]byte, classes []int, err error) {
+	input_data, classes = inputs.FixedVsRandom(l.rn, n, l.fixed)
+	return
+}
+
+func (l *library) do_one_computation(data []byte) []byte {
+	l.fn.call(data)
+	return data
+}
+
+// measure_call times the call itself, without the cgo overhead; the timings
+// which end up below the calibrated overhead are clamped to zero.
+func (l *library) measure_call(data []byte) ([]byte, int64) {
+	exec_time := l.fn.time(data) - l.fn.overhead
+	if exec_time < 0 {
+		exec_time = 0
+	}
+	return data, exec_time
+}
+
+func init() {
+	dudect.Register(new_target)
+}
@@ -0,0 +1,36 @@
+/*
+ * Two comparisons of the input against a secret, to be built as a shared
+ * library and assessed with the cgo target:
+ *
+ *	cc -O2 -shared -fPIC -o libcompare.so compare.c
+ *	dudect run cgo ./libcompare.so compare_leaky
+ *	dudect run cgo ./libcompare.so compare_const
+ *
+ * The result of the comparison is written into the first byte of the input.
+ */
+#include <stddef.h>
+#include <stdint.h>
+
+static const uint8_t secret[64];
+
+void compare_leaky(uint8_t *data, size_t len) {
+	size_t i;
+	uint8_t equal = 1;
+
+	for (i = 0; i < len && i < sizeof(secret); i++) {
+		if (data[i] != secret[i]) {
+			equal = 0;
+			break;
+		}
+	}
+	data[0] = equal;
+}
+
+void compare_const(uint8_t *data, size_t len) {
+	size_t i;
+	uint8_t diff = 0;
+
+	for (i = 0; i < len && i < sizeof(secret); i++)
+		diff |= data[i] ^ secret[i];
+	data[0] = (uint8_t)((diff - 1) >> 8) & 1;
+}
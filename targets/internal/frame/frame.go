@@ -0,0 +1,78 @@
+// Package frame implements the length-prefixed protocol used to talk to the
+// targets living outside of the harness: each frame is a big-endian uint32
+// length followed by that many bytes.
+package frame
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// MaxSize is the largest frame accepted, so that a misbehaving peer cannot
+// make us allocate up to 4 GiB.
+const MaxSize = 16 << 20
+
+// ErrProtocol is returned when the other side does not follow the protocol.
+var ErrProtocol = errors.New("frame: protocol error")
+
+// Conn reads and writes frames, reusing its buffers.
+type Conn struct {
+	r      *bufio.Reader
+	w      *bufio.Writer
+	header [4]byte
+	buf    []byte
+}
+
+func NewConn(r io.Reader, w io.Writer) *Conn {
+	return &Conn{r: bufio.NewReader(r), w: bufio.NewWriter(w)}
+}
+
+// WriteFrame writes and flushes a frame holding data.
+func (c *Conn) WriteFrame(data []byte) error {
+	if len(data) > MaxSize {
+		return fmt.Errorf("%w: a frame of %d bytes exceeds %d bytes", ErrProtocol, len(data), MaxSize)
+	}
+	binary.BigEndian.PutUint32(c.header[:], uint32(len(data)))
+	c.w.Write(c.header[:])
+	c.w.Write(data)
+	return c.w.Flush()
+}
+
+// ReadFrame reads a frame, whose content is only valid until the next read.
+// It returns io.EOF if the other side closed the connection between frames.
+func (c *Conn) ReadFrame() ([]byte, error) {
+	if _, err := io.ReadFull(c.r, c.header[:]); err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: reading a frame length: %w", ErrProtocol, err)
+	}
+	n := int(binary.BigEndian.Uint32(c.header[:]))
+	if n > MaxSize {
+		return nil, fmt.Errorf("%w: a frame of %d bytes exceeds %d bytes", ErrProtocol, n, MaxSize)
+	}
+	if cap(c.buf) < n {
+		c.buf = make([]byte, n)
+	}
+	c.buf = c.buf[:n]
+	if _, err := io.ReadFull(c.r, c.buf); err != nil {
+		return nil, fmt.Errorf("%w: reading a frame of %d bytes: %w", ErrProtocol, n, err)
+	}
+	return c.buf, nil
+}
+
+// RoundTrip sends data and returns the reply, which is only valid until the
+// next read.
+func (c *Conn) RoundTrip(data []byte) ([]byte, error) {
+	if err := c.WriteFrame(data); err != nil {
+		return nil, err
+	}
+	reply, err := c.ReadFrame()
+	if err == io.EOF {
+		err = fmt.Errorf("%w: connection closed before the reply", ErrProtocol)
+	}
+	return reply, err
+}
@@ -0,0 +1,62 @@
+package frame
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	var b bytes.Buffer
+	c := NewConn(&b, &b)
+	for _, data := range [][]byte{{}, {1}, bytes.Repeat([]byte{2}, 5000)} {
+		reply, err := c.RoundTrip(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(reply, data) {
+			t.Errorf("got a reply of %d bytes, want %d bytes", len(reply), len(data))
+		}
+	}
+}
+
+func TestMaxSize(t *testing.T) {
+	c := NewConn(nil, io.Discard)
+	if err := c.WriteFrame(make([]byte, MaxSize+1)); !errors.Is(err, ErrProtocol) {
+		t.Errorf("writing a frame exceeding MaxSize: got %v, want %v", err, ErrProtocol)
+	}
+
+	header := binary.BigEndian.AppendUint32(nil, MaxSize+1)
+	c = NewConn(bytes.NewReader(header), io.Discard)
+	if _, err := c.ReadFrame(); !errors.Is(err, ErrProtocol) {
+		t.Errorf("reading a frame exceeding MaxSize: got %v, want %v", err, ErrProtocol)
+	}
+}
+
+func TestEOF(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input []byte
+		eof   bool
+	}{
+		{"closed between frames", nil, true},
+		{"truncated length", []byte{0, 0}, false},
+		{"truncated frame", []byte{0, 0, 0, 4, 1, 2}, false},
+		{"missing frame", []byte{0, 0, 0, 4}, false},
+	} {
+		_, err := NewConn(bytes.NewReader(tc.input), io.Discard).ReadFrame()
+		if tc.eof && err != io.EOF {
+			t.Errorf("%s: got %v, want %v", tc.name, err, io.EOF)
+		}
+		if !tc.eof && !errors.Is(err, ErrProtocol) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, ErrProtocol)
+		}
+	}
+
+	// a reply which never comes is a protocol error.
+	if _, err := NewConn(bytes.NewReader(nil), io.Discard).RoundTrip([]byte{1}); !errors.Is(err, ErrProtocol) {
+		t.Errorf("round trip without a reply: got %v, want %v", err, ErrProtocol)
+	}
+}
@@ -0,0 +1,64 @@
+// Package inputs generates the fixed-vs-random inputs of the targets which
+// do not know anything about the function they assess.
+package inputs
+
+import (
+	"encoding/hex"
+	"flag"
+	"fmt"
+	mrand "math/rand"
+	"time"
+)
+
+// Fixed is the input of class 0, configured from the command line.
+type Fixed struct {
+	size  int
+	fixed string
+}
+
+// Flags binds the -size and -fixed flags to f.
+func (f *Fixed) Flags(fs *flag.FlagSet) {
+	fs.IntVar(&f.size, "size", 32, "size of the inputs")
+	fs.StringVar(&f.fixed, "fixed", "", "hex encoded input of class 0, zeros of the given size if empty")
+}
+
+// Value returns the input of class 0.
+func (f *Fixed) Value() ([]byte, error) {
+	if f.fixed != "" {
+		b, err := hex.DecodeString(f.fixed)
+		if err != nil {
+			return nil, fmt.Errorf("-fixed: %v", err)
+		}
+		return b, nil
+	}
+	if f.size <= 0 {
+		return nil, fmt.Errorf("-size must be positive, got %d", f.size)
+	}
+	return make([]byte, f.size), nil
+}
+
+// NewRand returns a generator of inputs seeded from the clock, which the
+// targets reseed when asked to.
+func NewRand() *mrand.Rand {
+	return mrand.New(mrand.NewSource(time.Now().UnixNano()))
+}
+
+// FixedVsRandom returns n inputs of len(fixed) bytes and their classes, drawn
+// from rn: the inputs of class 0 are copies of fixed while those of class 1
+// are random.
+func FixedVsRandom(rn *mrand.Rand, n int, fixed []byte) (input_data [][]byte, classes []int) {
+	input_data = make([][]byte, n)
+	classes = make([]int, n)
+
+	for i := 0; i < n; i++ {
+		classes[i] = rn.Intn(2)
+		data := make([]byte, len(fixed))
+		if classes[i] == 0 {
+			copy(data, fixed)
+		} else {
+			rn.Read(data)
+		}
+		input_data[i] = data
+	}
+	return
+}
@@ -16,17 +16,18 @@
 //  designed by Oscar Reparaz, Josep Balasch and Ingrid Verbauwhede, all credits due.
 // ------------------ AnomalRoil 2016 >
 
-package main
+package leftpad
 
 import (
-	"crypto/rand"
-	"fmt"
+	"crypto/subtle"
 	mrand "math/rand"
 	"time"
+
+	"github.com/AnomalRoil/go-dudect"
 )
 
-// leftPadConst returns a new slice of length size. The contents of input are right
-// aligned in the new slice, using the old Copy implementation
+// leftPad returns a new slice of length size. The contents of input are right
+// aligned in the new slice.
 func leftPad(input []byte, size int) (out []byte) {
 	n := len(input)
 	if n > size {
@@ -37,21 +38,28 @@ func leftPad(input []byte, size int) (out []byte) {
 	return
 }
 
-// For the leftPad test:
-func prepare_inputs() (input_data [][]byte, classes []int) {
-	input_data = make([][]byte, number_measurements)
-	classes = make([]int, number_measurements)
+// leftPadConst returns a new slice of length size. The contents of input are right
+// aligned in the new slice, using subtle.ConstantTimeCopy.
+func leftPadConst(input []byte, size int) (out []byte) {
+	n := len(input)
+	if n > size {
+		n = size
+	}
+	out = make([]byte, size)
+	subtle.ConstantTimeCopy(1, out[size-n:], input)
+	return
+}
 
-	rn := mrand.New(mrand.NewSource(time.Now().UnixNano()))
-	for i := 0; i < number_measurements; i++ {
+// For the leftPad test, the inputs being drawn from rn:
+func prepare_inputs(rn *mrand.Rand, n int) (input_data [][]byte, classes []int, err error) {
+	input_data = make([][]byte, n)
+	classes = make([]int, n)
+
+	for i := 0; i < n; i++ {
 		classes[i] = rn.Intn(2)
 		data := make([]byte, 256)
-		_, err := rand.Read(data)
+		rn.Read(data)
 		//data, err := hex.DecodeString("73e4952b02c526cccb40bc093f56a9e9065f366e7778de49fadaa91427526377af02f1bb5201e90a9a79bf82a03936f7dce806637b1114d395c14d718d95b909d5292475e79c01b1f7695f0d83ff15a1da819dca0f14e2bb2bb093b24c4364be13f9b65bf2943e1f8f5c2d493f6418e09e645f26c935bd2132ef928179e5e411a26038f78b1defc16b65c96e975cf03ab7e4be3dc0481f2dd4a047ab53f2edaddb13739ad98829bdbc58b520fb227246e5e8e34678d7fe5dcaf0835403e1f0dfb9d49956d9efcfd4afe8e1ba38609557c0e5a8acef75575cc575dc8c053a00e7f22bf077df6ab27a7cb47afd47f6f8ecb14f032ac42d06e705387707817340ba")
-		if err != nil {
-			fmt.Println("error:", err)
-			panic("err")
-		}
 		if classes[i] == 0 {
 			input_data[i] = data
 		} else {
@@ -63,11 +71,42 @@ func prepare_inputs() (input_data [][]byte, classes []int) {
 }
 
 // For the leftPad test:
-func do_one_computation(data []byte) {
+func do_one_computation(data []byte) []byte {
+	size := len(data)
+	if len(data) != 256 {
+		size = 256
+	}
+	return leftPad(data, size)
+}
+
+func do_one_computation_const(data []byte) []byte {
 	size := len(data)
 	if len(data) != 256 {
 		size = 256
 	}
-	out := leftPad(data, size)
-	out[0] = 0
+	return leftPadConst(data, size)
+}
+
+// new_target returns a target running computation on the leftPad inputs, which
+// are drawn from a generator of its own.
+func new_target(name, description string, computation func([]byte) []byte) *dudect.Target {
+	rn := mrand.New(mrand.NewSource(time.Now().UnixNano()))
+	return &dudect.Target{
+		Name:        name,
+		Description: description,
+		PrepareInputs: func(n int) ([][]byte, []int, error) {
+			return prepare_inputs(rn, n)
+		},
+		DoOneComputation: computation,
+		Seed:             rn.Seed,
+	}
+}
+
+func init() {
+	dudect.Register(func() *dudect.Target {
+		return new_target("leftpad", "leftPad on 256 bytes inputs versus 255 bytes inputs", do_one_computation)
+	})
+	dudect.Register(func() *dudect.Target {
+		return new_target("leftpad-const", "leftPadConst on 256 bytes inputs versus 255 bytes inputs", do_one_computation_const)
+	})
 }
@@ -0,0 +1,266 @@
+// Package network assesses whether the timing leak of a target survives a
+// network path: the inputs of the wrapped target are sent to a server, over
+// TCP using the length-prefixed frames of the exec target or over HTTP as the
+// body of POST requests, and the time of each round trip is measured.
+//
+// The server must reply with the result of the computation of the wrapped
+// target on each input, and with an empty reply to an empty input, which is
+// used to probe the network jitter. When no address is given, a local
+// stand-in server running the wrapped target is started.
+package network
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/AnomalRoil/go-dudect"
+	"github.com/AnomalRoil/go-dudect/targets/internal/frame"
+)
+
+const jitter_report_every = 16 // batches between two jitter reports
+
+// ErrMismatch is returned when the server does not compute the wrapped target.
+var ErrMismatch = errors.New("network: the server result differs from the local one")
+
+// remote is an instance of the net target.
+type remote struct {
+	use_http bool
+	timeout  time.Duration
+	probes   int
+	inner    *dudect.Target
+	target   *dudect.Target
+
+	conn      net.Conn
+	frames    *frame.Conn
+	client    *http.Client
+	url       string
+	server    io.Closer // the local stand-in server, if any
+	first_err error     // the first error met by a computation
+
+	batches int
+	// the round trip times of the empty probes, using Welford's method
+	rtt_n, rtt_mean, rtt_m2 float64
+	rtt_min                 float64
+}
+
+func new_target() *dudect.Target {
+	r := &remote{rtt_min: math.Inf(1)}
+	r.target = &dudect.Target{
+		Name:             "net",
+		Description:      "another target behind a TCP or HTTP server: net [-http] [-addr host:port] [-timeout d] [-probes n] target [target flags]",
+		PrepareInputs:    r.prepare_inputs,
+		DoOneComputation: r.do_one_computation,
+		CheckComputation: r.check_computation,
+		Configure:        r.configure,
+		Err:              func() error { return r.first_err },
+		Close:            r.close,
+	}
+	return r.target
+}
+
+func (r *remote) configure(args []string) error {
+	fs := flag.NewFlagSet("net", flag.ContinueOnError)
+	fs.BoolVar(&r.use_http, "http", false, "send the inputs over HTTP instead of raw TCP")
+	addr := fs.String("addr", "", "address of the server, a local stand-in server is started if empty")
+	fs.DurationVar(&r.timeout, "timeout", time.Second, "timeout of each request")
+	fs.IntVar(&r.probes, "probes", 100, "number of empty requests probing the jitter before each batch")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	var err error
+	if r.inner, err = dudect.LookupTarget(fs.Args()); err != nil {
+		return err
+	}
+	r.target.Seed = r.inner.Seed
+
+	where := *addr
+	if *addr == "" {
+		if *addr, err = r.serve_locally(); err != nil {
+			return err
+		}
+		where = "a local stand-in server on " + *addr
+	}
+	fmt.Printf("INFO: sending the inputs of %s to %s\n", r.inner.Name, where)
+
+	if r.use_http {
+		r.client = &http.Client{Timeout: r.timeout}
+		r.url = "http://" + *addr + "/"
+		return nil
+	}
+	if r.conn, err = net.DialTimeout("tcp", *addr, r.timeout); err != nil {
+		return err
+	}
+	r.frames = frame.NewConn(r.conn, r.conn)
+	return nil
+}
+
+// serve_locally starts a server computing the wrapped target on the loopback
+// interface, returning its address.
+func (r *remote) serve_locally() (string, error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	if r.use_http {
+		srv := &http.Server{Handler: http.HandlerFunc(r.serve_http)}
+		go srv.Serve(l)
+		r.server = srv
+	} else {
+		go r.serve_frames(l)
+		r.server = l
+	}
+	return l.Addr().String(), nil
+}
+
+func (r *remote) compute(data []byte) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	return r.inner.DoOneComputation(data)
+}
+
+func (r *remote) serve_frames(l net.Listener) {
+	for {
+		c, err := l.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer c.Close()
+			fc := frame.NewConn(c, c)
+			for {
+				data, err := fc.ReadFrame()
+				if err != nil {
+					return
+				}
+				if err := fc.WriteFrame(r.compute(data)); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+func (r *remote) serve_http(w http.ResponseWriter, req *http.Request) {
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Write(r.compute(data))
+}
+
+// round_trip sends data to the server and returns its reply, which is only
+// valid until the next round trip.
+func (r *remote) round_trip(data []byte) ([]byte, error) {
+	if r.use_http {
+		resp, err := r.client.Post(r.url, "application/octet-stream", bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("network: %s", resp.Status)
+		}
+		reply, err := io.ReadAll(io.LimitReader(resp.Body, frame.MaxSize+1))
+		if err == nil && len(reply) > frame.MaxSize {
+			err = fmt.Errorf("%w: a reply exceeds %d bytes", frame.ErrProtocol, frame.MaxSize)
+		}
+		return reply, err
+	}
+	r.conn.SetDeadline(time.Now().Add(r.timeout))
+	return r.frames.RoundTrip(data)
+}
+
+// probe_jitter times empty round trips, which only depend on the network path.
+func (r *remote) probe_jitter() error {
+	for i := 0; i < r.probes; i++ {
+		start := time.Now()
+		if _, err := r.round_trip(nil); err != nil {
+			return err
+		}
+		rtt := float64(time.Since(start).Nanoseconds())
+		r.rtt_n++
+		delta := rtt - r.rtt_mean
+		r.rtt_mean += delta / r.rtt_n
+		r.rtt_m2 += delta * (rtt - r.rtt_mean)
+		r.rtt_min = math.Min(r.rtt_min, rtt)
+	}
+	return nil
+}
+
+func (r *remote) jitter_report() {
+	if r.rtt_n < 2 {
+		return
+	}
+	fmt.Printf("INFO: network round trip of the probes: min %.1f µs, mean %.1f µs, jitter (std dev) %.1f µs over %.0f probes.\n",
+		r.rtt_min/1e3, r.rtt_mean/1e3, math.Sqrt(r.rtt_m2/(r.rtt_n-1))/1e3, r.rtt_n)
+}
+
+// prepare_inputs probes the network jitter and returns the inputs of the
+// wrapped target.
+func (r *remote) prepare_inputs(n int) ([][]byte, []int, error) {
+	if err := r.probe_jitter(); err != nil {
+		return nil, nil, err
+	}
+	if r.batches++; r.batches%jitter_report_every == 0 {
+		r.jitter_report()
+	}
+	return r.inner.PrepareInputs(n)
+}
+
+// do_one_computation sends data to the server, once an error is met the
+// remaining computations of the batch return right away and the error is
+// reported by the harness before the batch is recorded.
+func (r *remote) do_one_computation(data []byte) []byte {
+	if r.first_err != nil {
+		return nil
+	}
+	result, err := r.round_trip(data)
+	if err != nil {
+		r.first_err = err
+	}
+	return result
+}
+
+// check_computation makes sure the server computes the wrapped target.
+func (r *remote) check_computation(data, result []byte, class int) error {
+	if r.first_err != nil {
+		return r.first_err
+	}
+	if !bytes.Equal(result, r.inner.DoOneComputation(data)) {
+		return ErrMismatch
+	}
+	if r.inner.CheckComputation != nil {
+		return r.inner.CheckComputation(data, result, class)
+	}
+	return nil
+}
+
+func (r *remote) close() error {
+	r.jitter_report()
+	if r.conn != nil {
+		r.conn.Close()
+	}
+	if r.client != nil {
+		r.client.CloseIdleConnections()
+	}
+	if r.server != nil {
+		r.server.Close()
+	}
+	if r.inner != nil && r.inner.Close != nil {
+		return r.inner.Close()
+	}
+	return nil
+}
+
+func init() {
+	dudect.Register(new_target)
+}
@@ -0,0 +1,156 @@
+package network
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/AnomalRoil/go-dudect"
+	"github.com/AnomalRoil/go-dudect/targets/internal/frame"
+)
+
+// the reverse target is the trivial target wrapped by the tests, reversing
+// its inputs.
+func new_reverse() *dudect.Target {
+	return &dudect.Target{
+		Name: "reverse",
+		PrepareInputs: func(n int) ([][]byte, []int, error) {
+			input_data, classes := make([][]byte, n), make([]int, n)
+			for i := range input_data {
+				input_data[i], classes[i] = []byte{byte(i), 1, 2, 3}, i%2
+			}
+			return input_data, classes, nil
+		},
+		DoOneComputation: func(data []byte) []byte {
+			result := make([]byte, len(data))
+			for i := range data {
+				result[len(data)-1-i] = data[i]
+			}
+			return result
+		},
+	}
+}
+
+func init() {
+	dudect.Register(new_reverse)
+}
+
+// serve runs handle on every connection accepted on the loopback interface,
+// returning the address of the listener.
+func serve(t *testing.T, handle func(c net.Conn)) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			c, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer c.Close()
+				handle(c)
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// echo replies to each frame with its content, which is not the result of
+// the reverse target.
+func echo(c net.Conn) {
+	fc := frame.NewConn(c, c)
+	for {
+		data, err := fc.ReadFrame()
+		if err != nil || fc.WriteFrame(data) != nil {
+			return
+		}
+	}
+}
+
+// run measures one batch of n inputs as the harness does, returning the first
+// error of the checks and of Err.
+func run(t *testing.T, args []string, n int) error {
+	target, err := dudect.LookupTarget(append([]string{"net"}, args...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	input_data, classes, err := target.PrepareInputs(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results := make([][]byte, n)
+	for i := range input_data {
+		results[i] = append([]byte(nil), target.DoOneComputation(input_data[i])...)
+	}
+	if err := target.Err(); err != nil {
+		return err
+	}
+	for i := range input_data {
+		if err := target.CheckComputation(input_data[i], results[i], classes[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, args := range [][]string{{}, {"-http"}} {
+		if err := run(t, append(args, "-probes", "10", "reverse"), 100); err != nil {
+			t.Errorf("%q: %v", args, err)
+		}
+	}
+}
+
+func TestMismatch(t *testing.T) {
+	addr := serve(t, echo)
+	if err := run(t, []string{"-addr", addr, "-probes", "10", "reverse"}, 10); !errors.Is(err, ErrMismatch) {
+		t.Errorf("TCP: got %v, want %v", err, ErrMismatch)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		io.Copy(w, req.Body)
+	}))
+	defer srv.Close()
+	addr = srv.Listener.Addr().String()
+	if err := run(t, []string{"-http", "-addr", addr, "-probes", "10", "reverse"}, 10); !errors.Is(err, ErrMismatch) {
+		t.Errorf("HTTP: got %v, want %v", err, ErrMismatch)
+	}
+}
+
+func TestServerErrors(t *testing.T) {
+	// the server reads the requests but never replies.
+	silent := serve(t, func(c net.Conn) { io.Copy(io.Discard, c) })
+	err := run(t, []string{"-addr", silent, "-timeout", "50ms", "-probes", "0", "reverse"}, 10)
+	var net_err net.Error
+	if !errors.As(err, &net_err) || !net_err.Timeout() {
+		t.Errorf("silent server: got %v, want a timeout", err)
+	}
+
+	// the server closes the connection after the first request.
+	closing := serve(t, func(c net.Conn) { frame.NewConn(c, c).ReadFrame() })
+	if err := run(t, []string{"-addr", closing, "-probes", "0", "reverse"}, 10); !errors.Is(err, frame.ErrProtocol) {
+		t.Errorf("closing server: got %v, want %v", err, frame.ErrProtocol)
+	}
+
+	// the remaining computations of the batch return right away.
+	target, err := dudect.LookupTarget([]string{"net", "-addr", closing, "-probes", "0", "reverse"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	for i := 0; i < 3; i++ {
+		if result := target.DoOneComputation([]byte{1, 2}); result != nil {
+			t.Errorf("computation %d returned %v after the connection was closed", i, result)
+		}
+	}
+	if err := target.CheckComputation([]byte{1, 2}, nil, 0); !errors.Is(err, frame.ErrProtocol) {
+		t.Errorf("check after the connection was closed: got %v, want %v", err, frame.ErrProtocol)
+	}
+}
@@ -0,0 +1,113 @@
+package rsa
+
+import (
+	stdrsa "crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	mrand "math/rand"
+	"os"
+)
+
+// defaultExponent is the public exponent of the generated keys.
+const defaultExponent = 65537
+
+// randomPrime returns a random prime of the given number of bits, whose two
+// most significant bits are set so that the product of two such primes has
+// twice as many bits.
+func randomPrime(rn *mrand.Rand, bits int) *big.Int {
+	b := make([]byte, (bits+7)/8)
+	for {
+		rn.Read(b)
+		// clear the bits above the requested size, then set the two most
+		// significant ones and make the number odd.
+		excess := uint(len(b)*8 - bits)
+		b[0] &= byte(0xff >> excess)
+		if excess < 7 {
+			b[0] |= 0xc0 >> excess
+		} else {
+			b[0] |= 1
+			b[1] |= 0x80
+		}
+		b[len(b)-1] |= 1
+		p := new(big.Int).SetBytes(b)
+		if p.ProbablyPrime(20) {
+			return p
+		}
+	}
+}
+
+// generateKey returns a new key whose modulus has the given number of bits
+// and is the product of the given number of primes, drawing them from rn.
+func generateKey(rn *mrand.Rand, bits, nprimes int) (*PrivateKey, error) {
+	if nprimes < 2 {
+		return nil, fmt.Errorf("rsa: a key needs at least 2 primes, got %d", nprimes)
+	}
+	if bits/nprimes < 32 {
+		return nil, fmt.Errorf("rsa: a %d bits key is too small for %d primes", bits, nprimes)
+	}
+	e := big.NewInt(defaultExponent)
+NextSetOfPrimes:
+	for {
+		primes := make([]*big.Int, nprimes)
+		n := new(big.Int).Set(bigOne)
+		totient := new(big.Int).Set(bigOne)
+		todo := bits
+		for i := range primes {
+			// the remaining bits are shared among the remaining primes.
+			primes[i] = randomPrime(rn, todo/(nprimes-i))
+			todo -= primes[i].BitLen()
+			for j := 0; j < i; j++ {
+				if primes[i].Cmp(primes[j]) == 0 {
+					continue NextSetOfPrimes
+				}
+			}
+			n.Mul(n, primes[i])
+			totient.Mul(totient, new(big.Int).Sub(primes[i], bigOne))
+		}
+		if n.BitLen() != bits {
+			continue
+		}
+		d := new(big.Int).ModInverse(e, totient)
+		if d == nil {
+			continue
+		}
+		return &PrivateKey{
+			PublicKey: PublicKey{N: n, E: defaultExponent},
+			D:         d,
+			Primes:    primes,
+		}, nil
+	}
+}
+
+// loadKey reads a private key from a PEM or DER file, in the PKCS #1 or the
+// PKCS #8 format.
+func loadKey(path string) (*PrivateKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if block, _ := pem.Decode(b); block != nil {
+		b = block.Bytes
+	}
+	var parsed interface{}
+	parsed, err = x509.ParsePKCS1PrivateKey(b)
+	if err != nil {
+		var err8 error
+		if parsed, err8 = x509.ParsePKCS8PrivateKey(b); err8 != nil {
+			return nil, fmt.Errorf("rsa: %s: not a PKCS #1 key (%v) nor a PKCS #8 key (%v)", path, err, err8)
+		}
+	}
+	std, ok := parsed.(*stdrsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("rsa: " + path + " does not hold an RSA private key")
+	}
+	priv := &PrivateKey{
+		PublicKey: PublicKey{N: std.N, E: std.E},
+		D:         std.D,
+		Primes:    std.Primes,
+	}
+	return priv, priv.Validate()
+}
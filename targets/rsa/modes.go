@@ -0,0 +1,96 @@
+package rsa
+
+import (
+	"crypto/sha256"
+	"io"
+	mrand "math/rand"
+	"strings"
+
+	"github.com/AnomalRoil/go-dudect"
+)
+
+// A mode selects how the private key operation of DecryptOAEP is done, so that
+// a leak can be attributed to the modular exponentiation, the unblinding or
+// the padding check.
+type mode struct {
+	// blinding blinds the private key operation with a random reader seeded
+	// with the blinding seed of the target.
+	blinding bool
+	// crt decrypts with the Chinese remainder theorem, using the values
+	// computed by Precompute once if precompute is set, or on each
+	// decryption otherwise.
+	crt, precompute bool
+}
+
+// all_modes returns every distinct mode, precompute being meaningless without
+// the CRT.
+func all_modes() []mode {
+	var modes []mode
+	for _, blinding := range []bool{false, true} {
+		modes = append(modes,
+			mode{blinding: blinding},
+			mode{blinding: blinding, crt: true},
+			mode{blinding: blinding, crt: true, precompute: true})
+	}
+	return modes
+}
+
+func (m mode) String() string {
+	parts := []string{"exp"}
+	if m.crt {
+		parts[0] = "crt"
+		if m.precompute {
+			parts = append(parts, "precompute")
+		}
+	}
+	if m.blinding {
+		parts = append(parts, "blinding")
+	}
+	return strings.Join(parts, ",")
+}
+
+// private_key returns the key to decrypt with in the given mode.
+func (o *oaep) private_key(m mode) *PrivateKey {
+	if !m.crt {
+		return o.key
+	}
+	if m.precompute {
+		return o.precomputed_key
+	}
+	// the CRT values are computed by decrypt's caller, within the timed region.
+	k := &PrivateKey{PublicKey: o.key.PublicKey, D: o.key.D, Primes: o.key.Primes}
+	k.Precompute()
+	return k
+}
+
+// computation returns the function decrypting an input in the given mode. It
+// returns the plaintext, or the error message when the decryption fails as it
+// does on every input.
+func (o *oaep) computation(m mode) func(data []byte) []byte {
+	var random io.Reader
+	if m.blinding {
+		random = mrand.New(mrand.NewSource(o.blinding_seed))
+	}
+	return func(data []byte) []byte {
+		p, err := DecryptOAEP(sha256.New(), random, o.private_key(m), data, []byte(""))
+		if err != nil {
+			return []byte(err.Error())
+		}
+		return p
+	}
+}
+
+// variants returns a target for each mode, to be compared side by side.
+func (o *oaep) variants() []*dudect.Target {
+	var targets []*dudect.Target
+	for _, m := range all_modes() {
+		targets = append(targets, &dudect.Target{
+			Name:             "rsa-oaep[" + m.String() + "]",
+			PrepareInputs:    o.prepare_inputs,
+			DoOneComputation: o.computation(m),
+			CheckComputation: o.check_class,
+			Seed:             o.rn.Seed,
+		})
+	}
+	return targets
+}
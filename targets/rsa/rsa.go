@@ -15,23 +15,33 @@
 //  designed by Oscar Reparaz, Josep Balasch and Ingrid Verbauwhede, all credits due.
 // ------------------ AnomalRoil 2016 >
 
-package main
+package rsa
 
 import (
 	"crypto"
 	"crypto/rand"
-	"crypto/sha256"
 	"crypto/subtle"
 	"errors"
 	"fmt"
 	"hash"
 	"io"
-	"log"
 	"math/big"
-	mrand "math/rand"
-	"time"
+
+	"github.com/AnomalRoil/go-dudect"
 )
 
+// ErrInvalidNumber is returned when a string cannot be parsed as a big number.
+var ErrInvalidNumber = errors.New("rsa: bad number")
+
+// fromBase16 returns a new Big.Int from an hexadecimal string, as found in the go crypto tests suite
+func fromBase16(base16 string) (*big.Int, error) {
+	i, ok := new(big.Int).SetString(base16, 16)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidNumber, base16)
+	}
+	return i, nil
+}
+
 // Please note that for ease of use, we are exposing the oracle at first
 // and then later trying to use a timing oracle
 // RSA library, a bit stripped:
@@ -97,17 +107,32 @@ type PrivateKey struct {
 	Precomputed PrecomputedValues
 }
 
-// The private key used
-var test2048Key = &PrivateKey{
-	PublicKey: PublicKey{
-		N: fromBase16("b3a6b8dac202f283b94ed148cf5eedd6a9990ee2cc42e9955c5b06ec40c23a205de3c0ed7f0fbc29b3d38cdffc9129f2e8b2f54a0df471e7f27c0f2eac1298b68a802ae1f2dccf2ebae134b4cbc3866b3b1e65b44ab541b80609a62c09322e46e5e1ff3e05eb2af7ca5f4df2c62f3107d4647bee1a77d3f5c787c583ee834b25bbb0fcbb4ed9e97cef8e8f2b8f947ebdefda9c1e0af23ac7b2445ba3b3d483a76f007fca88cd1f13b2f85b1d435c3000bd1d6fa245489c6239e8b1b6648dcbcb2463589f76df043188e84cb458858ed1f1de3ae89025111854602d9bd6cc6da5369e9c7c32430d25129f23ce37d281883f4de1bd5787d52815c13c2009829fdd"),
-		E: 17,
-	},
-	D: fromBase16("3f680501ea1f286ab9df9528c1a908a61dbd8cc88453d9f87af2f36271357ded4e506235b45fe80eb7f04fd6956069288e5d47838c74646ffb3ad82e97159f4f7c2d3c4fbf20c19805b8e56cfc9f5c9e5119c98aed30ea04b6d63aa6215d0146330478340216c3defc21a30a6410a7e4a550a435eb3959de466c2797f9d3fc671416ef3451c534b74e2793f123adba26ebfd2daca1ea8a7d60737528b85dcbae2c310adc1d9d931e4c016fe24a938f13c5b98226ce19320866c73b006d07f0066a5d009f83be55fd8f994fcdc08679ae13ff7a7b581de3523cee82f3955087f2cbcd648087839ebbb2876498fbb9ed78e69bf0903794a30a77409c614f2d9719"),
-	Primes: []*big.Int{
-		fromBase16("e15e6eb27860142b2b68b68b4260dc9c595fdc9dfa5eb2f9ed4a530c70ffbb1a6c201ff4a292d58134a5ebd52776806ece5168d1e7becdf20ddb4212dc57e1994f197b858e5202163831bbbbeef99a9a0c3a0aef8080582a7a4e188bbd27780d6cd6e1c65753b54a0969589f35c494e5654d75c0be6c46f04070d9a3fa69b94b"),
-		fromBase16("cc1192f49975bff51160601fbd7212b34f2d68c19b25aa1533b2e74e8dcb0774db0016663cfbd36751a3b246f3439a2f3e93c0b7c0426b585e2e4877a89f6cca5297b0ab489c63cce4842edc1d644620025054f0eb500a2f82c3a2089d40c9bd3301c89f05a5161c8f60d8d2e37f2121a1f14263fba1159a2e1952130417ba77"),
-	},
+// The private key used, its values are parsed by loadTestKey.
+const (
+	test2048N  = "b3a6b8dac202f283b94ed148cf5eedd6a9990ee2cc42e9955c5b06ec40c23a205de3c0ed7f0fbc29b3d38cdffc9129f2e8b2f54a0df471e7f27c0f2eac1298b68a802ae1f2dccf2ebae134b4cbc3866b3b1e65b44ab541b80609a62c09322e46e5e1ff3e05eb2af7ca5f4df2c62f3107d4647bee1a77d3f5c787c583ee834b25bbb0fcbb4ed9e97cef8e8f2b8f947ebdefda9c1e0af23ac7b2445ba3b3d483a76f007fca88cd1f13b2f85b1d435c3000bd1d6fa245489c6239e8b1b6648dcbcb2463589f76df043188e84cb458858ed1f1de3ae89025111854602d9bd6cc6da5369e9c7c32430d25129f23ce37d281883f4de1bd5787d52815c13c2009829fdd"
+	test2048D  = "3f680501ea1f286ab9df9528c1a908a61dbd8cc88453d9f87af2f36271357ded4e506235b45fe80eb7f04fd6956069288e5d47838c74646ffb3ad82e97159f4f7c2d3c4fbf20c19805b8e56cfc9f5c9e5119c98aed30ea04b6d63aa6215d0146330478340216c3defc21a30a6410a7e4a550a435eb3959de466c2797f9d3fc671416ef3451c534b74e2793f123adba26ebfd2daca1ea8a7d60737528b85dcbae2c310adc1d9d931e4c016fe24a938f13c5b98226ce19320866c73b006d07f0066a5d009f83be55fd8f994fcdc08679ae13ff7a7b581de3523cee82f3955087f2cbcd648087839ebbb2876498fbb9ed78e69bf0903794a30a77409c614f2d9719"
+	test2048P0 = "e15e6eb27860142b2b68b68b4260dc9c595fdc9dfa5eb2f9ed4a530c70ffbb1a6c201ff4a292d58134a5ebd52776806ece5168d1e7becdf20ddb4212dc57e1994f197b858e5202163831bbbbeef99a9a0c3a0aef8080582a7a4e188bbd27780d6cd6e1c65753b54a0969589f35c494e5654d75c0be6c46f04070d9a3fa69b94b"
+	test2048P1 = "cc1192f49975bff51160601fbd7212b34f2d68c19b25aa1533b2e74e8dcb0774db0016663cfbd36751a3b246f3439a2f3e93c0b7c0426b585e2e4877a89f6cca5297b0ab489c63cce4842edc1d644620025054f0eb500a2f82c3a2089d40c9bd3301c89f05a5161c8f60d8d2e37f2121a1f14263fba1159a2e1952130417ba77"
+)
+
+// loadTestKey builds the private key used from its hexadecimal values.
+func loadTestKey() (*PrivateKey, error) {
+	var values [4]*big.Int
+	for i, base16 := range []string{test2048N, test2048D, test2048P0, test2048P1} {
+		v, err := fromBase16(base16)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return &PrivateKey{
+		PublicKey: PublicKey{
+			N: values[0],
+			E: 17,
+		},
+		D:      values[1],
+		Primes: []*big.Int{values[2], values[3]},
+	}, nil
 }
 
 // Public returns the public key corresponding to priv.
@@ -476,6 +501,7 @@ func DecryptOAEP(hash hash.Hash, random io.Reader, priv *PrivateKey, ciphertext
 	if err != nil {
 		return nil, err
 	}
+	dudect.Probe("decrypt")
 
 	hash.Write(label)
 	lHash := hash.Sum(nil)
@@ -487,6 +513,7 @@ func DecryptOAEP(hash hash.Hash, random io.Reader, priv *PrivateKey, ciphertext
 	// leak the number of leading zeros. It's not clear that we can do
 	// anything about this.)
 	em := leftPad(m.Bytes(), k)
+	dudect.Probe("leftPad")
 
 	firstByteIsZero := subtle.ConstantTimeByteEq(em[0], 0)
 
@@ -495,6 +522,7 @@ func DecryptOAEP(hash hash.Hash, random io.Reader, priv *PrivateKey, ciphertext
 
 	mgf1XOR(seed, hash, db)
 	mgf1XOR(db, hash, seed)
+	dudect.Probe("unmask")
 
 	lHash2 := db[0:hash.Size()]
 
@@ -555,45 +583,3 @@ func leftPadConst(input []byte, size int) (out []byte) {
 	subtle.ConstantTimeCopy(1, out[size-n:], input)
 	return
 }
-
-// the DUDECT functions :
-func prepare_inputs() (input_data [][]byte, classes []int) {
-	input_data = make([][]byte, number_measurements)
-	classes = make([]int, number_measurements)
-	//fmt.Println("Preparing input")
-	rn := mrand.New(mrand.NewSource(time.Now().UnixNano()))
-
-	lowerB := new(big.Int).Lsh(bigOne, 2040)
-	upperB := new(big.Int).Sub(test2048Key.N, lowerB)
-	for i := 0; i < number_measurements; i++ {
-		classes[i] = rn.Intn(2)
-		var tmp *big.Int
-		if classes[i] == 0 { // we don't want a 00 MSB
-			tmp = new(big.Int).Rand(rn, upperB)
-			tmp.Add(test2048Key.N, lowerB)
-		} else {
-			tmp = new(big.Int).Rand(rn, lowerB)
-		}
-		// we craft the cipher, since we know the key:
-		c := encrypt(new(big.Int), &test2048Key.PublicKey, tmp)
-		data := c.Bytes()
-		// another test to perform is when the cipher is correct:
-		if classes[i] == 1 {
-			var err error
-			data, err = EncryptOAEP(sha256.New(), rand.Reader, &test2048Key.PublicKey, data[len(data)/2+5:], []byte(""))
-			if err != nil {
-				log.Fatal(err)
-			}
-		}
-		input_data[i] = data
-	}
-	return
-}
-
-func do_one_computation(data []byte) {
-
-	p, err := DecryptOAEP(sha256.New(), nil, test2048Key, data, []byte(""))
-	if err == nil {
-		err = fmt.Errorf("decryption successful: %s", string(p))
-	}
-}
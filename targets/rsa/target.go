@@ -0,0 +1,176 @@
+package rsa
+
+import (
+	"crypto/sha256"
+	"flag"
+	"fmt"
+	"math/big"
+	mrand "math/rand"
+	"time"
+
+	"github.com/AnomalRoil/go-dudect"
+)
+
+// The target models Manger's attack on RSA-OAEP, whose oracle tells whether
+// the first byte of the decrypted plaintext is zero: the plaintexts of class 0
+// have a non-zero first byte, while those of class 1 have exactly zeros
+// leading zero bytes. Both fail the OAEP padding check, so that the only
+// difference between the classes is the one exploited by the attack.
+//
+// The key is read from key_path if set, generated with bits and nprimes if
+// bits is not 0, its primes being drawn from a generator seeded with seed, or
+// else it is the test key.
+type oaep struct {
+	key *PrivateKey
+	// precomputed_key is key along with its CRT values.
+	precomputed_key *PrivateKey
+	zeros           int
+	rn              *mrand.Rand
+
+	key_path string
+	bits     int
+	nprimes  int
+	seed     int64
+
+	// mode is the mode of the target, set by its flags.
+	mode          mode
+	blinding_seed int64
+
+	target *dudect.Target
+}
+
+func new_oaep() *oaep {
+	o := &oaep{
+		zeros:         1,
+		rn:            mrand.New(mrand.NewSource(time.Now().UnixNano())),
+		nprimes:       2,
+		mode:          mode{precompute: true},
+		blinding_seed: 1,
+	}
+	o.target = &dudect.Target{
+		Name:             "rsa-oaep",
+		Description:      "DecryptOAEP on ciphertexts whose plaintext has leading zero bytes or not: rsa-oaep [-key file | -bits n [-primes n] [-seed n]] [-zeros n] [-blinding] [-crt] [-precompute=false]",
+		PrepareInputs:    o.prepare_inputs,
+		DoOneComputation: o.computation(o.mode),
+		CheckComputation: o.check_class,
+		Configure:        o.configure,
+		Variants:         o.variants,
+		Seed:             o.rn.Seed,
+	}
+	return o
+}
+
+func (o *oaep) configure(args []string) error {
+	fs := flag.NewFlagSet("rsa-oaep", flag.ContinueOnError)
+	fs.StringVar(&o.key_path, "key", "", "PEM or DER file holding the private key, in the PKCS #1 or PKCS #8 format")
+	fs.IntVar(&o.bits, "bits", 0, "size of a generated key, e.g. 1024, 2048, 3072 or 4096, the test key is used if 0")
+	fs.IntVar(&o.nprimes, "primes", 2, "number of primes of a generated key")
+	fs.Int64Var(&o.seed, "seed", 0, "seed of the key generation, drawn from the clock if 0")
+	fs.IntVar(&o.zeros, "zeros", 1, "number of leading zero bytes of the plaintexts of class 1")
+	fs.BoolVar(&o.mode.blinding, "blinding", false, "blind the private key operation")
+	fs.Int64Var(&o.blinding_seed, "blinding-seed", 1, "seed of the random reader used for blinding")
+	fs.BoolVar(&o.mode.crt, "crt", false, "decrypt with the CRT")
+	fs.BoolVar(&o.mode.precompute, "precompute", true, "compute the CRT values once rather than on each decryption")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("rsa-oaep: unexpected arguments %q", fs.Args())
+	}
+	o.target.DoOneComputation = o.computation(o.mode)
+	return o.load_key()
+}
+
+func (o *oaep) load_key() (err error) {
+	switch {
+	case o.key_path != "":
+		o.key, err = loadKey(o.key_path)
+	case o.bits != 0:
+		if o.seed == 0 {
+			o.seed = time.Now().UnixNano()
+		}
+		o.key, err = generateKey(mrand.New(mrand.NewSource(o.seed)), o.bits, o.nprimes)
+		if err == nil {
+			fmt.Printf("rsa-oaep: generated a %d bits key with %d primes from seed %d\n", o.bits, o.nprimes, o.seed)
+		}
+	default:
+		o.key, err = loadTestKey()
+	}
+	if err != nil {
+		return err
+	}
+	key := o.key
+	o.precomputed_key = &PrivateKey{PublicKey: key.PublicKey, D: key.D, Primes: key.Primes}
+	o.precomputed_key.Precompute()
+	k := (key.N.BitLen() + 7) / 8
+	if k < 2*sha256.Size+2 {
+		return fmt.Errorf("rsa-oaep: a %d bits key is too small for OAEP with SHA-256", key.N.BitLen())
+	}
+	if o.zeros < 1 || o.zeros >= k {
+		return fmt.Errorf("rsa-oaep: -zeros must be in [1, %d], got %d", k-1, o.zeros)
+	}
+	return nil
+}
+
+// plaintext_bounds returns the range [low, high) of the plaintexts of the
+// given class, for a modulus of k bytes.
+func (o *oaep) plaintext_bounds(class, k int) (low, high *big.Int) {
+	if class == 0 {
+		return new(big.Int).Lsh(bigOne, uint(8*(k-1))), o.key.N
+	}
+	return new(big.Int).Lsh(bigOne, uint(8*(k-o.zeros-1))), new(big.Int).Lsh(bigOne, uint(8*(k-o.zeros)))
+}
+
+// prepare_inputs crafts the ciphertexts of plaintexts drawn uniformly from the
+// range of their class, which we can do since we know the key.
+func (o *oaep) prepare_inputs(n int) (input_data [][]byte, classes []int, err error) {
+	if o.key == nil {
+		if err := o.load_key(); err != nil {
+			return nil, nil, err
+		}
+	}
+	input_data = make([][]byte, n)
+	classes = make([]int, n)
+	k := (o.key.N.BitLen() + 7) / 8
+	var bounds [2][2]*big.Int
+	for class := range bounds {
+		bounds[class][0], bounds[class][1] = o.plaintext_bounds(class, k)
+		bounds[class][1] = new(big.Int).Sub(bounds[class][1], bounds[class][0])
+	}
+
+	for i := 0; i < n; i++ {
+		classes[i] = o.rn.Intn(2)
+		b := bounds[classes[i]]
+		m := new(big.Int).Rand(o.rn, b[1])
+		m.Add(m, b[0])
+		c := encrypt(new(big.Int), &o.key.PublicKey, m)
+		input_data[i] = leftPad(c.Bytes(), k)
+	}
+	return
+}
+
+// check_class decrypts the ciphertext to make sure its plaintext has the
+// number of leading zero bytes of its class, and that the computation failed
+// with ErrDecryption like it does on every other input.
+func (o *oaep) check_class(data, result []byte, class int) error {
+	m, err := decrypt(nil, o.key, new(big.Int).SetBytes(data))
+	if err != nil {
+		return err
+	}
+	k := (o.key.N.BitLen() + 7) / 8
+	got, want := k-len(m.Bytes()), 0
+	if class == 1 {
+		want = o.zeros
+	}
+	if got != want {
+		return fmt.Errorf("the plaintext has %d leading zero bytes, expected %d", got, want)
+	}
+	if string(result) != ErrDecryption.Error() {
+		return fmt.Errorf("expected %v, got %q", ErrDecryption, result)
+	}
+	return nil
+}
+
+func init() {
+	dudect.Register(func() *dudect.Target { return new_oaep().target })
+}
@@ -0,0 +1,120 @@
+package rsa
+
+import (
+	"bytes"
+	"crypto/rand"
+	stdrsa "crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	mrand "math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateKey(t *testing.T) {
+	for _, tc := range []struct{ bits, nprimes int }{{1023, 2}, {1024, 2}, {1024, 3}, {1024, 4}} {
+		priv, err := generateKey(mrand.New(mrand.NewSource(1)), tc.bits, tc.nprimes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if priv.N.BitLen() != tc.bits || len(priv.Primes) != tc.nprimes {
+			t.Errorf("generated a %d bits modulus with %d primes, want %d bits and %d primes",
+				priv.N.BitLen(), len(priv.Primes), tc.bits, tc.nprimes)
+		}
+		if err := priv.Validate(); err != nil {
+			t.Errorf("%d bits key with %d primes: %v", tc.bits, tc.nprimes, err)
+		}
+	}
+
+	a, _ := generateKey(mrand.New(mrand.NewSource(2)), 1024, 2)
+	b, _ := generateKey(mrand.New(mrand.NewSource(2)), 1024, 2)
+	if a.N.Cmp(b.N) != 0 {
+		t.Error("two keys generated from the same seed differ")
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	std, err := stdrsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(std)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs1 := x509.MarshalPKCS1PrivateKey(std)
+	dir := t.TempDir()
+	for name, b := range map[string][]byte{
+		"pkcs1.pem": pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: pkcs1}),
+		"pkcs1.der": pkcs1,
+		"pkcs8.pem": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}),
+		"pkcs8.der": pkcs8,
+	} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, b, 0600); err != nil {
+			t.Fatal(err)
+		}
+		priv, err := loadKey(path)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if priv.N.Cmp(std.N) != 0 || priv.E != std.E || priv.D.Cmp(std.D) != 0 || len(priv.Primes) != len(std.Primes) {
+			t.Errorf("%s: the loaded key differs from the written one", name)
+		}
+	}
+
+	garbage := []byte("not a key")
+	path := filepath.Join(dir, "garbage")
+	if err := os.WriteFile(path, garbage, 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err1 := x509.ParsePKCS1PrivateKey(garbage)
+	_, err8 := x509.ParsePKCS8PrivateKey(garbage)
+	if _, err := loadKey(path); err == nil || !strings.Contains(err.Error(), err1.Error()) || !strings.Contains(err.Error(), err8.Error()) {
+		t.Errorf("loading a malformed key: got %v, want the errors of both formats", err)
+	}
+}
+
+func TestPrepareInputs(t *testing.T) {
+	for _, tc := range []struct{ bits, nprimes, zeros int }{{0, 2, 1}, {0, 2, 3}, {1024, 3, 1}} {
+		o := new_oaep()
+		o.bits, o.nprimes, o.zeros = tc.bits, tc.nprimes, tc.zeros
+		if err := o.load_key(); err != nil {
+			t.Fatal(err)
+		}
+		input_data, classes, err := o.prepare_inputs(100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range input_data {
+			result := o.target.DoOneComputation(input_data[i])
+			if err := o.check_class(input_data[i], result, classes[i]); err != nil {
+				t.Errorf("%+v, input %d of class %d: %v", tc, i, classes[i], err)
+			}
+		}
+	}
+}
+
+func TestModes(t *testing.T) {
+	for _, n := range []int{2, 3} {
+		o := new_oaep()
+		o.bits, o.nprimes = 1024, n
+		if err := o.load_key(); err != nil {
+			t.Fatal(err)
+		}
+		msg := []byte("constant time")
+		c, err := EncryptOAEP(sha256.New(), o.rn, &o.key.PublicKey, msg, []byte(""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, m := range all_modes() {
+			if got := o.computation(m)(c); !bytes.Equal(got, msg) {
+				t.Errorf("%d primes, %s: decrypted %q, want %q", n, m, got, msg)
+			}
+		}
+	}
+}
@@ -0,0 +1,66 @@
+// Command example is a program speaking the protocol of the exec target: it
+// compares each input with a secret of zeros, returning early on the first
+// difference, which is a timing leak dudect detects with:
+//
+//	dudect run exec ./example
+//	dudect run exec -reported ./example -reported
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"io"
+	"os"
+	"time"
+)
+
+func equal(secret, data []byte) bool {
+	for i := range secret {
+		if secret[i] != data[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func main() {
+	reported := flag.Bool("reported", false, "prefix each reply with the execution time")
+	flag.Parse()
+
+	in := bufio.NewReader(os.Stdin)
+	out := bufio.NewWriter(os.Stdout)
+	var header [4]byte
+	var data, secret, reply []byte
+	for {
+		if _, err := io.ReadFull(in, header[:]); err != nil {
+			return // the harness closed our input
+		}
+		n := binary.BigEndian.Uint32(header[:])
+		if uint32(cap(data)) < n {
+			data = make([]byte, n)
+			secret = make([]byte, n)
+		}
+		data, secret = data[:n], secret[:n]
+		if _, err := io.ReadFull(in, data); err != nil {
+			os.Exit(1)
+		}
+
+		start := time.Now()
+		result := byte(0)
+		if equal(secret, data) {
+			result = 1
+		}
+		elapsed := time.Since(start)
+
+		reply = reply[:0]
+		if *reported {
+			reply = binary.BigEndian.AppendUint64(reply, uint64(elapsed.Nanoseconds()))
+		}
+		reply = append(reply, result)
+		binary.BigEndian.PutUint32(header[:], uint32(len(reply)))
+		out.Write(header[:])
+		out.Write(reply)
+		out.Flush()
+	}
+}
@@ -0,0 +1,157 @@
+// Package subprocess assesses external programs as a black box: the program is
+// launched once and fed with the inputs over its standard input, the time of
+// each round trip being measured, or the time it reports itself.
+//
+// Each input is written as a big-endian uint32 length followed by the input
+// bytes, and the program must reply in the same way with its result. When the
+// program reports its own timings, the first 8 bytes of each reply hold the
+// execution time in nanoseconds as a big-endian uint64. The standard input is
+// closed at the end of the run, at which point the program must exit.
+package subprocess
+
+import (
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	mrand "math/rand"
+	"os"
+	"os/exec"
+
+	"github.com/AnomalRoil/go-dudect"
+	"github.com/AnomalRoil/go-dudect/targets/internal/frame"
+	"github.com/AnomalRoil/go-dudect/targets/internal/inputs"
+)
+
+// process is a running program, talking the length-prefixed protocol.
+type process struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	conn  *frame.Conn
+	err   error // the first error met by a computation
+}
+
+func start(argv []string) (*process, error) {
+	cmd := exec.Command(argv[0], argv[1:]...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &process{cmd: cmd, stdin: stdin, conn: frame.NewConn(stdout, stdin)}, nil
+}
+
+func (p *process) close() error {
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
+
+// program is an instance of the exec target.
+type program struct {
+	fixed_flags inputs.Fixed
+	fixed       []byte
+	rn          *mrand.Rand
+	child       *process
+	target      *dudect.Target
+}
+
+func new_target() *dudect.Target {
+	p := &program{rn: inputs.NewRand()}
+	p.target = &dudect.Target{
+		Name:             "exec",
+		Description:      "an external program, fixed vs random inputs: exec [-size n] [-fixed hex] [-reported] program [args]",
+		PrepareInputs:    p.prepare_inputs,
+		DoOneComputation: p.do_one_computation,
+		CheckComputation: p.check_computation,
+		Configure:        p.configure,
+		Err:              p.err,
+		Close:            p.close,
+		Seed:             p.rn.Seed,
+	}
+	return p.target
+}
+
+func (p *program) configure(args []string) error {
+	fs := flag.NewFlagSet("exec", flag.ContinueOnError)
+	p.fixed_flags.Flags(fs)
+	reported := fs.Bool("reported", false, "use the execution times reported by the program")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return fmt.Errorf("exec: expected a program to run")
+	}
+	var err error
+	if p.fixed, err = p.fixed_flags.Value(); err != nil {
+		return fmt.Errorf("exec: %v", err)
+	}
+	if *reported {
+		p.target.Measure = p.measure_reported
+	}
+
+	p.child, err = start(fs.Args())
+	return err
+}
+
+func (p *program) close() error {
+	if p.child == nil {
+		return nil
+	}
+	return p.child.close()
+}
+
+// err returns the first error met by a computation.
+func (p *program) err() error {
+	if p.child == nil {
+		return nil
+	}
+	return p.child.err
+}
+
+// prepare_inputs returns fixed inputs for class 0 and random ones for class 1.
+func (p *program) prepare_inputs(n int) (input_data [][]byte, classes []int, err error) {
+	input_data, classes = inputs.FixedVsRandom(p.rn, n, p.fixed)
+	return
+}
+
+// do_one_computation sends data to the program, once an error is met the
+// remaining computations of the batch return right away and the error is
+// reported by the harness before the batch is recorded.
+func (p *program) do_one_computation(data []byte) []byte {
+	if p.child.err != nil {
+		return nil
+	}
+	result, err := p.child.conn.RoundTrip(data)
+	if err != nil {
+		p.child.err = err
+	}
+	return result
+}
+
+func (p *program) measure_reported(data []byte) ([]byte, int64) {
+	result := p.do_one_computation(data)
+	if p.child.err != nil {
+		return nil, 0
+	}
+	if len(result) < 8 {
+		p.child.err = fmt.Errorf("%w: a reply of %d bytes cannot hold a timing", frame.ErrProtocol, len(result))
+		return nil, 0
+	}
+	return result[8:], int64(binary.BigEndian.Uint64(result[:8]))
+}
+
+// check_computation makes sure the program replied to data.
+func (p *program) check_computation(data, result []byte, class int) error {
+	return p.child.err
+}
+
+func init() {
+	dudect.Register(new_target)
+}
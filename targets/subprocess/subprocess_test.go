@@ -0,0 +1,119 @@
+package subprocess
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/AnomalRoil/go-dudect"
+	"github.com/AnomalRoil/go-dudect/targets/internal/frame"
+)
+
+// build_example builds the example program, returning its path.
+func build_example(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "example")
+	if out, err := exec.Command("go", "build", "-o", path, "./example").CombinedOutput(); err != nil {
+		t.Fatalf("building the example: %v\n%s", err, out)
+	}
+	return path
+}
+
+func TestTimings(t *testing.T) {
+	example := build_example(t)
+	for _, reported := range []bool{false, true} {
+		args := []string{"exec", example}
+		if reported {
+			args = []string{"exec", "-reported", example, "-reported"}
+		}
+		target, err := dudect.LookupTarget(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		input_data, classes, err := target.PrepareInputs(100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var total int64
+		for i := range input_data {
+			var result []byte
+			if reported {
+				var exec_time int64
+				result, exec_time = target.Measure(input_data[i])
+				total += exec_time
+			} else {
+				result = target.DoOneComputation(input_data[i])
+			}
+			// the inputs of class 0 are the zeros the example compares with.
+			if want := []byte{byte(1 - classes[i])}; !bytes.Equal(result, want) {
+				t.Errorf("reported %v, input %d of class %d: got %v, want %v", reported, i, classes[i], result, want)
+			}
+		}
+		if err := target.Err(); err != nil {
+			t.Errorf("reported %v: %v", reported, err)
+		}
+		if reported && total <= 0 {
+			t.Errorf("the example reported a total of %d ns", total)
+		}
+		if err := target.Close(); err != nil {
+			t.Errorf("reported %v: closing: %v", reported, err)
+		}
+	}
+}
+
+func TestShortReportedReply(t *testing.T) {
+	// the example does not prefix its replies with a timing without -reported.
+	target, err := dudect.LookupTarget([]string{"exec", "-reported", build_example(t)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer target.Close()
+	if result, _ := target.Measure(make([]byte, 32)); result != nil {
+		t.Errorf("got the result %v of a short reply", result)
+	}
+	if err := target.Err(); !errors.Is(err, frame.ErrProtocol) {
+		t.Errorf("got %v, want %v", err, frame.ErrProtocol)
+	}
+}
+
+// crash_after is the environment variable making the test binary behave as a
+// program replying to the given number of frames before crashing.
+const crash_after = "DUDECT_CRASH_AFTER"
+
+func TestMain(m *testing.M) {
+	if frames, err := strconv.Atoi(os.Getenv(crash_after)); err == nil {
+		c := frame.NewConn(os.Stdin, os.Stdout)
+		for i := 0; i < frames; i++ {
+			if _, err := c.ReadFrame(); err != nil {
+				os.Exit(1)
+			}
+			c.WriteFrame([]byte{0})
+		}
+		os.Exit(2)
+	}
+	os.Exit(m.Run())
+}
+
+func TestCrash(t *testing.T) {
+	// 10500 frames are enough for two batches along with their checks, the
+	// program crashing in the middle of the third batch.
+	const frames = 10500
+	t.Setenv(crash_after, strconv.Itoa(frames))
+	trace := filepath.Join(t.TempDir(), "trace")
+	if err := dudect.Main([]string{"run", "-trace", trace, "exec", os.Args[0]}); err == nil {
+		t.Fatal("the run went on after the program crashed")
+	}
+
+	b, err := os.ReadFile(trace)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const batch = 3000
+	if n := bytes.Count(b, []byte("\n")); n == 0 || n%batch != 0 || n >= frames {
+		t.Errorf("the trace holds %d measurements, want the whole batches measured before the crash", n)
+	}
+}
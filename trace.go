@@ -0,0 +1,65 @@
+package dudect
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+// ErrTraceFormat is returned when a trace file cannot be parsed.
+var ErrTraceFormat = errors.New("dudect: malformed trace file")
+
+// trace_path is the file to which every measurement is written, if not empty.
+// Each line of a trace holds the class of an input and its execution time in
+// nanoseconds, separated by a space.
+var trace_path string
+var trace_writer *bufio.Writer
+
+// create_trace creates the trace file written to by write_trace.
+func create_trace(path string) (*os.File, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	trace_writer = bufio.NewWriter(f)
+	return f, nil
+}
+
+// write_trace appends a batch of measurements to the trace file, and flushes
+// it so that the trace stays usable whenever the run is interrupted.
+func write_trace(exec_times []int64, classes []int) error {
+	for i := 0; i < number_measurements; i++ {
+		fmt.Fprintf(trace_writer, "%d %d\n", classes[i], exec_times[i])
+	}
+	return trace_writer.Flush()
+}
+
+// read_trace calls fn on each of the measurements of the given trace file.
+func read_trace(path string, fn func(class int, exec_time int64) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for line := 1; ; line++ {
+		var class int
+		var exec_time int64
+		_, err := fmt.Fscanf(r, "%d %d\n", &class, &exec_time)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrTraceFormat, line, err)
+		}
+		if !(class == 0 || class == 1) {
+			return fmt.Errorf("%w: line %d: %w: got %d", ErrTraceFormat, line, ErrInvalidClass, class)
+		}
+		if err := fn(class, exec_time); err != nil {
+			return fmt.Errorf("%w: line %d: %v", ErrTraceFormat, line, err)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package dudect
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+)
+
+func TestPercentile(t *testing.T) {
+	// a shuffled 0, 1, ..., 999 has its p-quantile at 1000p.
+	x := make([]int64, 1000)
+	for i := range x {
+		x[i] = int64(i)
+	}
+	rand.New(rand.NewSource(1)).Shuffle(len(x), func(i, j int) { x[i], x[j] = x[j], x[i] })
+
+	for _, tc := range []struct {
+		perc float64
+		want int64
+	}{
+		{0.001, 1},
+		{0.25, 250},
+		{0.5, 500},
+		{0.9, 900},
+		{0.999, 999},
+	} {
+		got, err := percentile(x, tc.perc)
+		if err != nil {
+			t.Fatalf("percentile(%v): %v", tc.perc, err)
+		}
+		if got != tc.want {
+			t.Errorf("percentile(%v) = %d, want %d", tc.perc, got, tc.want)
+		}
+	}
+}
+
+func TestPercentileRange(t *testing.T) {
+	x := []int64{3, 1, 2}
+	for _, perc := range []float64{0, 0.1, 1, 1.5, -0.5} {
+		if _, err := percentile(x, perc); !errors.Is(err, ErrPercentileRange) {
+			t.Errorf("percentile(%v) returned %v, want ErrPercentileRange", perc, err)
+		}
+	}
+}